@@ -0,0 +1,150 @@
+package corn
+
+import (
+	"corn/ast"
+)
+
+// ParseAST parses the input into a typed syntax tree, without evaluating it.
+//
+// This allows tools such as linters to inspect the structure of a configuration,
+// for example which keys are set or where inputs are referenced.
+// A `*SyntaxError` is returned if the input is not valid Corn.
+func ParseAST(input string) (*ast.Config, error) {
+	rule, err := compile(input)
+
+	if err != nil {
+		return nil, locateError(err, input)
+	}
+
+	return toAST(rule), nil
+}
+
+// Converts the `ruleConfig` produced by `parse` into its typed equivalent.
+func toAST(rule Rule[any]) *ast.Config {
+	config := &ast.Config{Span: astSpan(rule.Span)}
+
+	for _, child := range rule.Rules {
+		switch child.Id {
+		case ruleAssignBlock:
+			block := &ast.AssignBlock{Span: astSpan(child.Span)}
+
+			for _, assignment := range child.Rules {
+				input := assignment.Rules[0]
+				value := assignment.Rules[1].Rules[0]
+
+				block.Assignments = append(block.Assignments, &ast.Assignment{
+					Span:  astSpan(assignment.Span),
+					Input: toASTInput(input),
+					Value: toASTValue(value),
+				})
+			}
+
+			config.Let = block
+		case ruleObject:
+			config.Object = toASTObject(child)
+		}
+	}
+
+	return config
+}
+
+func astSpan(span Span) ast.Span {
+	return ast.Span{Start: span.Start, End: span.End}
+}
+
+func toASTInput(rule Rule[any]) *ast.Input {
+	return &ast.Input{Span: astSpan(rule.Span), Name: (*rule.Data).(string)}
+}
+
+// Spreads only hold the name of their input,
+// which always ends the spread.
+func toASTSpread(rule Rule[any]) *ast.Spread {
+	name := (*rule.Data).(string)
+	input := &ast.Input{Span: ast.Span{Start: rule.Span.End - len(name), End: rule.Span.End}, Name: name}
+
+	return &ast.Spread{Span: astSpan(rule.Span), Input: input}
+}
+
+func toASTObject(rule Rule[any]) *ast.Object {
+	object := &ast.Object{Span: astSpan(rule.Span)}
+
+	for _, child := range rule.Rules {
+		switch child.Id {
+		case ruleSpread:
+			object.Entries = append(object.Entries, toASTSpread(child))
+		case rulePair:
+			pathRule := child.Rules[0]
+			path := &ast.Path{Span: astSpan(pathRule.Span)}
+
+			for _, segment := range pathRule.Rules {
+				path.Segments = append(path.Segments, &ast.PathSegment{Span: astSpan(segment.Span), Name: (*segment.Data).(string)})
+			}
+
+			object.Entries = append(object.Entries, &ast.Pair{
+				Span:  astSpan(child.Span),
+				Path:  path,
+				Value: toASTValue(child.Rules[1]),
+			})
+		}
+	}
+
+	return object
+}
+
+func toASTArray(rule Rule[any]) *ast.Array {
+	array := &ast.Array{Span: astSpan(rule.Span)}
+
+	for _, child := range rule.Rules {
+		if child.Id == ruleSpread {
+			array.Elements = append(array.Elements, toASTSpread(child))
+		} else {
+			array.Elements = append(array.Elements, toASTValue(child).(ast.Element))
+		}
+	}
+
+	return array
+}
+
+func toASTString(rule Rule[any]) *ast.String {
+	str := &ast.String{Span: astSpan(rule.Span)}
+
+	for _, child := range rule.Rules {
+		var part ast.StringPart
+
+		switch child.Id {
+		case ruleCharSequence:
+			part = &ast.CharSequence{Span: astSpan(child.Span), Text: (*child.Data).(string)}
+		case ruleCharEscape:
+			part = &ast.CharEscape{Span: astSpan(child.Span), Char: (*child.Data).(rune)}
+		case ruleInput:
+			part = toASTInput(child)
+		}
+
+		str.Parts = append(str.Parts, part)
+	}
+
+	return str
+}
+
+func toASTValue(rule Rule[any]) ast.Value {
+	span := astSpan(rule.Span)
+
+	switch rule.Id {
+	case ruleObject:
+		return toASTObject(rule)
+	case ruleArray:
+		return toASTArray(rule)
+	case ruleString:
+		return toASTString(rule)
+	case ruleInput:
+		return toASTInput(rule)
+	case ruleInteger:
+		return &ast.Integer{Span: span, Value: (*rule.Data).(int64)}
+	case ruleFloat:
+		return &ast.Float{Span: span, Value: (*rule.Data).(float64)}
+	case ruleBoolean:
+		return &ast.Boolean{Span: span, Value: (*rule.Data).(bool)}
+	default:
+		return &ast.Null{Span: span}
+	}
+}
@@ -0,0 +1,189 @@
+// Package ast declares the types used to represent the syntax tree of a Corn configuration.
+//
+// Trees are produced by `corn.ParseAST`,
+// and can be traversed using `Walk` or `Inspect`.
+// Unlike the lossless tree produced by `corn.ParseCST`,
+// comments, whitespace and punctuation are not kept.
+package ast
+
+// Span holds the byte offsets of a node within the source.
+// `End` is the offset immediately after the node.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Extent returns the span itself, so that every node embedding a `Span` implements `Node`.
+func (span Span) Extent() Span {
+	return span
+}
+
+// Node is implemented by every node in the tree.
+type Node interface {
+	Extent() Span
+}
+
+// Value is implemented by nodes which can appear on the right of `=`:
+// `*Object`, `*Array`, `*String`, `*Input`, `*Integer`, `*Float`, `*Boolean` and `*Null`.
+type Value interface {
+	Node
+	valueNode()
+}
+
+// Entry is implemented by nodes which can appear in an object: `*Pair` and `*Spread`.
+type Entry interface {
+	Node
+	entryNode()
+}
+
+// Element is implemented by nodes which can appear in an array:
+// any `Value`, and `*Spread`.
+type Element interface {
+	Node
+	elementNode()
+}
+
+// StringPart is implemented by nodes which can appear in a string:
+// `*CharSequence`, `*CharEscape` and `*Input`.
+type StringPart interface {
+	Node
+	stringPartNode()
+}
+
+// Config is the root of the tree.
+type Config struct {
+	Span
+	// Assignments in the `let` block, or nil if there is none
+	Let    *AssignBlock
+	Object *Object
+}
+
+// AssignBlock is a `let { } in` block.
+type AssignBlock struct {
+	Span
+	Assignments []*Assignment
+}
+
+// Assignment is an assignment of a value to an input in the `let` block,
+// such as `$foo = 42`.
+type Assignment struct {
+	Span
+	Input *Input
+	Value Value
+}
+
+// Object is a `{ }` block of entries.
+type Object struct {
+	Span
+	Entries []Entry
+}
+
+// Pair is an entry assigning a value to a path, such as `foo.bar = 42`.
+type Pair struct {
+	Span
+	Path  *Path
+	Value Value
+}
+
+// Path is the dotted key on the left of a `Pair`.
+type Path struct {
+	Span
+	Segments []*PathSegment
+}
+
+// PathSegment is a single key within a `Path`.
+type PathSegment struct {
+	Span
+	// Name of the key, with any quotes removed
+	Name string
+}
+
+// Spread is a `..$input` entry in an object or array.
+type Spread struct {
+	Span
+	Input *Input
+}
+
+// Array is a `[ ]` block of elements.
+type Array struct {
+	Span
+	Elements []Element
+}
+
+// String is a double-quoted string.
+//
+// Its parts are as written in the source,
+// so leading whitespace has not yet been trimmed from multi-line strings.
+type String struct {
+	Span
+	Parts []StringPart
+}
+
+// CharSequence is a run of literal characters within a `String`.
+type CharSequence struct {
+	Span
+	Text string
+}
+
+// CharEscape is an escape sequence within a `String`, such as `\n` or `\u0041`.
+type CharEscape struct {
+	Span
+	Char rune
+}
+
+// Input is a reference to an input such as `$foo`,
+// either as a value, in a spread, in a string or as the target of an `Assignment`.
+type Input struct {
+	Span
+	// Name of the input, including the leading `$`
+	Name string
+}
+
+// Integer is an integer literal, in decimal or hex.
+type Integer struct {
+	Span
+	Value int64
+}
+
+// Float is a floating point literal.
+type Float struct {
+	Span
+	Value float64
+}
+
+// Boolean is a `true` or `false` literal.
+type Boolean struct {
+	Span
+	Value bool
+}
+
+// Null is a `null` literal.
+type Null struct {
+	Span
+}
+
+func (*Object) valueNode()  {}
+func (*Array) valueNode()   {}
+func (*String) valueNode()  {}
+func (*Input) valueNode()   {}
+func (*Integer) valueNode() {}
+func (*Float) valueNode()   {}
+func (*Boolean) valueNode() {}
+func (*Null) valueNode()    {}
+
+func (*Pair) entryNode()   {}
+func (*Spread) entryNode() {}
+
+func (*Object) elementNode()  {}
+func (*Array) elementNode()   {}
+func (*String) elementNode()  {}
+func (*Input) elementNode()   {}
+func (*Integer) elementNode() {}
+func (*Float) elementNode()   {}
+func (*Boolean) elementNode() {}
+func (*Null) elementNode()    {}
+func (*Spread) elementNode()  {}
+
+func (*CharSequence) stringPartNode() {}
+func (*CharEscape) stringPartNode()   {}
+func (*Input) stringPartNode()        {}
@@ -0,0 +1,73 @@
+package ast
+
+// A Visitor's Visit method is called for each node encountered by `Walk`.
+// If the returned visitor is not nil,
+// `Walk` visits each of the node's children with it,
+// followed by a call of `Visit(nil)`.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree in depth-first order,
+// starting by calling `v.Visit(node)`.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Config:
+		if n.Let != nil {
+			Walk(v, n.Let)
+		}
+
+		Walk(v, n.Object)
+	case *AssignBlock:
+		for _, assignment := range n.Assignments {
+			Walk(v, assignment)
+		}
+	case *Assignment:
+		Walk(v, n.Input)
+		Walk(v, n.Value)
+	case *Object:
+		for _, entry := range n.Entries {
+			Walk(v, entry)
+		}
+	case *Pair:
+		Walk(v, n.Path)
+		Walk(v, n.Value)
+	case *Path:
+		for _, segment := range n.Segments {
+			Walk(v, segment)
+		}
+	case *Spread:
+		Walk(v, n.Input)
+	case *Array:
+		for _, element := range n.Elements {
+			Walk(v, element)
+		}
+	case *String:
+		for _, part := range n.Parts {
+			Walk(v, part)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses the tree in depth-first order,
+// calling `f` for each node followed by `f(nil)` once its children have been visited.
+// If `f` returns false, the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
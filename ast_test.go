@@ -0,0 +1,58 @@
+package corn
+
+import (
+	"corn/ast"
+	"strings"
+	"testing"
+)
+
+func TestParseAST(t *testing.T) {
+	input := "let { $host = \"localhost\" } in {\n    server.'host name' = \"http://$host\\n\"\n    ports = [ 80 ..$extra ]\n    ..$base\n    tls = { enabled = true ratio = 0.5 key = null }\n}"
+	config, err := ParseAST(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Let == nil || len(config.Let.Assignments) != 1 {
+		t.Fatalf("expected one assignment, got %+v", config.Let)
+	}
+
+	assertEqual(t, config.Let.Assignments[0].Input.Name, "$host")
+
+	pair := config.Object.Entries[0].(*ast.Pair)
+	assertEqual(t, pair.Path.Segments[1].Name, "host name")
+	assertEqual(t, input[pair.Start:pair.End], "server.'host name' = \"http://$host\\n\"")
+
+	str := pair.Value.(*ast.String)
+
+	if len(str.Parts) != 3 || str.Parts[2].(*ast.CharEscape).Char != '\n' {
+		t.Fatalf("unexpected string parts %+v", str.Parts)
+	}
+
+	var inputs []string
+	var depth, maxDepth int
+
+	ast.Inspect(config, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+
+		depth++
+		maxDepth = max(maxDepth, depth)
+
+		if ref, ok := node.(*ast.Input); ok {
+			extent := node.Extent()
+			inputs = append(inputs, ref.Name+"@"+input[extent.Start:extent.End])
+		}
+
+		return true
+	})
+
+	assertEqual(t, strings.Join(inputs, " "), "$host@$host $host@$host $extra@$extra $base@$base")
+
+	if depth != 0 || maxDepth != 7 {
+		t.Fatalf("unexpected depth %d, max %d", depth, maxDepth)
+	}
+}
@@ -0,0 +1,557 @@
+// Command corn evaluates, validates and queries Corn configuration files.
+//
+// Usage:
+//
+//	corn <command> [flags] [file]
+//
+// The commands are:
+//
+//	eval     evaluate a file and print the result
+//	check    check that a file is valid
+//	fmt      format files in canonical style
+//	get      print the value at a path
+//	query    print the values matching a query
+//	convert  convert a file to another format
+//
+// Files are read from stdin when omitted or given as `-`.
+package main
+
+import (
+	"bytes"
+	"corn"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+const usage = `usage: corn <command> [flags] [file]
+
+commands:
+  eval     evaluate a file and print the result
+  check    check that a file is valid
+  fmt      format files in canonical style
+  get      print the value at a path
+  query    print the values matching a query
+  convert  convert a file to another format
+
+Run 'corn <command> -h' for details of a command's flags.
+`
+
+var commands = map[string]func(args []string, stdout io.Writer, stderr io.Writer) error{
+	"eval":    runEval,
+	"check":   runCheck,
+	"fmt":     runFmt,
+	"get":     runGet,
+	"query":   runQuery,
+	"convert": runConvert,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Runs the command named by the first argument and returns the exit code,
+// which is 1 if the command fails and 2 if it is used incorrectly.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	name := args[0]
+
+	if name == "-h" || name == "-help" || name == "help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	command, ok := commands[name]
+
+	if !ok {
+		fmt.Fprintf(stderr, "corn: unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	err := command(args[1:], stdout, stderr)
+
+	// usage errors have already been reported along with the usage
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func newFlagSet(name string, usage string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: corn "+name+" "+usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// Parses the flags, which prints any error along with the usage.
+// Returns `flag.ErrHelp` for every error, so that it is not reported again.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+
+	return nil
+}
+
+// Collects repeated `-input name=value` flags.
+type inputFlags map[string]any
+
+func (inputs inputFlags) String() string {
+	return ""
+}
+
+func (inputs inputFlags) Set(arg string) error {
+	name, value, ok := strings.Cut(arg, "=")
+
+	if !ok {
+		return errors.New("expected name=value")
+	}
+
+	inputs[name] = value
+	return nil
+}
+
+func runEval(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("eval", "[-format json|yaml|toml|corn] [-input name=value]... [-strict] [file]", stderr)
+	format := flags.String("format", "json", "output format, one of json, yaml, toml or corn")
+	inputs := inputFlags{}
+	flags.Var(inputs, "input", "set an input as a string, for example `region=eu-west-1`")
+	strict := flags.Bool("strict", false, "report unused, duplicate and shadowed inputs as errors")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	file, err := singleFile(flags.Args())
+
+	if err != nil {
+		return err
+	}
+
+	evaluation, err := evaluateFile(file, corn.Options{Inputs: inputs, Strict: *strict})
+
+	if err != nil {
+		return err
+	}
+
+	return writeValue(stdout, evaluation.Value, *format)
+}
+
+func runCheck(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("check", "[-strict] [file]...", stderr)
+	strict := flags.Bool("strict", false, "report unused, duplicate and shadowed inputs as errors")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var errs []error
+
+	for _, file := range files {
+		_, err := evaluateFile(file, corn.Options{Strict: *strict})
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func runFmt(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("fmt", "[-w] [-l] [file]...", stderr)
+	write := flags.Bool("w", false, "write the result back to the file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var errs []error
+
+	for _, file := range files {
+		err := formatFile(file, stdout, *write, *list)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func formatFile(file string, stdout io.Writer, write bool, list bool) error {
+	input, err := readFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	output, err := corn.Format(input)
+
+	if err != nil {
+		return describeError(file, err)
+	}
+
+	if list && output != input {
+		fmt.Fprintln(stdout, file)
+	}
+
+	if write && file != "-" {
+		if output == input {
+			return nil
+		}
+
+		info, err := os.Stat(file)
+
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(file, []byte(output), info.Mode().Perm())
+	}
+
+	if !list {
+		_, err = io.WriteString(stdout, output)
+	}
+
+	return err
+}
+
+func runGet(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("get", "[-format json|yaml|toml|corn] <path> [file]", stderr)
+	format := flags.String("format", "json", "output format for objects and arrays, one of json, yaml, toml or corn")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	file, err := singleFile(flags.Args()[1:])
+
+	if err != nil {
+		return err
+	}
+
+	evaluation, err := evaluateFile(file, corn.Options{})
+
+	if err != nil {
+		return err
+	}
+
+	value, err := evaluation.Get(flags.Arg(0))
+
+	if err != nil {
+		return describeError(file, err)
+	}
+
+	return printValue(stdout, value, *format)
+}
+
+func runQuery(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("query", "[-format json|yaml|toml|corn] <query> [file]", stderr)
+	format := flags.String("format", "json", "output format for objects and arrays, one of json, yaml, toml or corn")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	query, err := corn.ParseQuery(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	file, err := singleFile(flags.Args()[1:])
+
+	if err != nil {
+		return err
+	}
+
+	evaluation, err := evaluateFile(file, corn.Options{})
+
+	if err != nil {
+		return err
+	}
+
+	for _, value := range query.Select(evaluation.Value) {
+		err = printValue(stdout, value, *format)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runConvert(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("convert", "[-from corn|json] -to json|yaml|toml|corn [-extract] [-o output] [file]", stderr)
+	from := flags.String("from", "corn", "input format, one of corn or json")
+	to := flags.String("to", "", "output format, one of json, yaml, toml or corn")
+	extract := flags.Bool("extract", false, "move repeated values into inputs in a let block, with -to corn")
+	output := flags.String("o", "", "write the output to a file instead of stdout")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *to == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	if *extract && *to != "corn" {
+		return errors.New("-extract can only be used with -to corn")
+	}
+
+	file, err := singleFile(flags.Args())
+
+	if err != nil {
+		return err
+	}
+
+	var write func(w io.Writer) error
+
+	switch *from {
+	case "corn":
+		evaluation, err := evaluateFile(file, corn.Options{})
+
+		if err != nil {
+			return err
+		}
+
+		write = func(w io.Writer) error {
+			return convertValue(w, evaluation.Value, *to, *extract)
+		}
+	case "json":
+		source, err := convertJSON(file)
+
+		if err != nil {
+			return err
+		}
+
+		// write the converted source directly to keep its formatting
+		if *to == "corn" && !*extract {
+			write = func(w io.Writer) error {
+				_, err := io.WriteString(w, source)
+				return err
+			}
+
+			break
+		}
+
+		evaluation, err := corn.Evaluate(source)
+
+		if err != nil {
+			return describeError(file, err)
+		}
+
+		write = func(w io.Writer) error {
+			return convertValue(w, evaluation.Value, *to, *extract)
+		}
+	default:
+		return errors.New("unknown format `" + *from + "`")
+	}
+
+	if *output == "" {
+		return write(stdout)
+	}
+
+	out, err := os.Create(*output)
+
+	if err != nil {
+		return err
+	}
+
+	err = write(out)
+
+	return errors.Join(err, out.Close())
+}
+
+func convertValue(w io.Writer, value corn.Value, format string, extract bool) error {
+	if !extract {
+		return writeValue(w, value, format)
+	}
+
+	output, err := corn.MarshalWithOptions(value, corn.MarshalOptions{Indent: "    ", ExtractInputs: true})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
+
+// Reads a JSON file and returns it as Corn source.
+func convertJSON(file string) (string, error) {
+	input, err := readFile(file)
+
+	if err != nil {
+		return "", err
+	}
+
+	source, err := corn.FromJSON([]byte(input))
+
+	if err != nil {
+		return "", describeError(file, err)
+	}
+
+	return string(source), nil
+}
+
+func singleFile(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "-", nil
+	case 1:
+		return args[0], nil
+	default:
+		return "", errors.New("expected a single file, got " + strings.Join(args, " "))
+	}
+}
+
+func readFile(file string) (string, error) {
+	var data []byte
+	var err error
+
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+
+	return string(data), err
+}
+
+func evaluateFile(file string, options corn.Options) (corn.Evaluation, error) {
+	input, err := readFile(file)
+
+	if err != nil {
+		return corn.Evaluation{}, err
+	}
+
+	evaluation, err := corn.EvaluateWithOptions(input, options)
+
+	if err != nil {
+		return evaluation, describeError(file, err)
+	}
+
+	return evaluation, nil
+}
+
+// Prefixes the error with the file name and appends its source snippet, if any.
+// Errors combined by `errors.Join` are each described in turn.
+func describeError(file string, err error) error {
+	if file == "-" {
+		file = "<stdin>"
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+
+		for _, err := range joined.Unwrap() {
+			errs = append(errs, describeError(file, err))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	var syntaxErr *corn.SyntaxError
+	var evalErr *corn.EvalError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s:%s: syntax error: %s\n%s", file, syntaxErr.Position, syntaxErr.Msg, syntaxErr.Snippet)
+	case errors.As(err, &evalErr):
+		return fmt.Errorf("%s:%s: %s\n%s", file, evalErr.Position, evalErr.Msg, evalErr.Snippet)
+	default:
+		return fmt.Errorf("%s: %w", file, err)
+	}
+}
+
+// Writes a single value for use in scripts,
+// printing strings without quotes.
+func printValue(w io.Writer, value corn.Value, format string) error {
+	if str, ok := value.(string); ok {
+		_, err := fmt.Fprintln(w, str)
+		return err
+	}
+
+	return writeValue(w, value, format)
+}
+
+func writeValue(w io.Writer, value corn.Value, format string) error {
+	var output []byte
+	var err error
+
+	switch format {
+	case "json":
+		output, err = json.MarshalIndent(value, "", "  ")
+	case "yaml":
+		output, err = corn.ToYAML(value)
+		output = bytes.TrimSuffix(output, []byte("\n"))
+	case "toml":
+		output, err = corn.ToTOML(value)
+		output = bytes.TrimSuffix(output, []byte("\n"))
+	case "corn":
+		if _, ok := value.(*orderedmap.OrderedMap); !ok {
+			return errors.New("only objects can be written as corn, got " + fmt.Sprintf("%T", value))
+		}
+
+		output, err = corn.MarshalIndent(value, "", "    ")
+	default:
+		return errors.New("unknown format `" + format + "`")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
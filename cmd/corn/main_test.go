@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	config := writeFile("config.corn", `let { $port = 8080 } in { server = { host = "localhost" port = $port } tags = [ "a" "b" ] }`)
+	unformatted := writeFile("unformatted.corn", "{a=1}")
+	unused := writeFile("unused.corn", `let { $unused = 1 } in { a = 1 }`)
+	invalid := writeFile("invalid.corn", `{ a = }`)
+	missing := writeFile("missing.corn", `{ a = $missing }`)
+	data := writeFile("data.json", `{"name": "app", "port": 80}`)
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"eval", config}, 0, "{\n  \"server\": {\n    \"host\": \"localhost\",\n    \"port\": 8080\n  },\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n", ""},
+		{[]string{"eval", "-format", "corn", "-input", "port=80", config}, 0, "{\n    server = {\n        host = \"localhost\"\n        port = \"80\"\n    }\n    tags = [\n        \"a\"\n        \"b\"\n    ]\n}\n", ""},
+		{[]string{"eval", missing}, 1, "", missing + ":1:7: input '$missing' does not exist"},
+		{[]string{"eval", config, config}, 1, "", "expected a single file"},
+		{[]string{"eval", "-bogus", config}, 2, "", "flag provided but not defined: -bogus\nusage: corn eval"},
+		{[]string{"eval", "-input", "port", config}, 2, "", `invalid value "port" for flag -input: expected name=value`},
+		{[]string{"check", config, unused}, 0, "", ""},
+		{[]string{"check", "-strict", config, unused}, 1, "", unused + ":1:7: input `$unused` is declared but never used"},
+		{[]string{"check", invalid}, 1, "", invalid + ":1:7: syntax error"},
+		{[]string{"fmt", unformatted}, 0, "{\n    a = 1\n}\n", ""},
+		{[]string{"fmt", "-l", config, unformatted}, 0, config + "\n" + unformatted + "\n", ""},
+		{[]string{"fmt", invalid}, 1, "", invalid + ":1:7: syntax error"},
+		{[]string{"get", "server.port", config}, 0, "8080\n", ""},
+		{[]string{"get", "server.host", config}, 0, "localhost\n", ""},
+		{[]string{"get", "server.missing", config}, 1, "", config + ": key `server.missing` does not exist"},
+		{[]string{"get"}, 2, "", "usage: corn get"},
+		{[]string{"query", "tags[*]", config}, 0, "a\nb\n", ""},
+		{[]string{"convert", "-to", "yaml", config}, 0, "server:\n  host: localhost\n  port: 8080\ntags:\n  - a\n  - b\n", ""},
+		{[]string{"convert", "-from", "json", "-to", "corn", data}, 0, "{\n    name = \"app\"\n    port = 80\n}\n", ""},
+		{[]string{"convert", "-extract", "-to", "json", config}, 1, "", "-extract can only be used with -to corn"},
+		{[]string{"convert", config}, 2, "", "usage: corn convert"},
+		{[]string{"unknown"}, 2, "", `unknown command "unknown"`},
+		{[]string{}, 2, "", "usage: corn <command>"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(test.args, &stdout, &stderr)
+
+		if code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d: %s", test.args, test.code, code, stderr.String())
+		}
+
+		if stdout.String() != test.stdout {
+			t.Errorf("%v: expected output %q, got %q", test.args, test.stdout, stdout.String())
+		}
+
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("%v: expected error containing %q, got %q", test.args, test.stderr, stderr.String())
+		}
+
+		// errors are reported once
+		if test.stderr != "" && strings.Count(stderr.String(), test.stderr) != 1 {
+			t.Errorf("%v: expected error once, got %q", test.args, stderr.String())
+		}
+	}
+
+	// every command is covered above
+	for name := range commands {
+		covered := false
+
+		for _, test := range tests {
+			covered = covered || (len(test.args) > 0 && test.args[0] == name)
+		}
+
+		if !covered {
+			t.Errorf("no test for command %q", name)
+		}
+	}
+}
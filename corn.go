@@ -1,7 +1,8 @@
 package corn
 
 import (
-	"errors"
+	"strconv"
+	"strings"
 )
 
 // Evaluates the input Corn string, returning an `Evaluation`.
@@ -13,27 +14,207 @@ import (
 //     The map represents the resultant Corn object.
 //     Nested objects use nested ordered maps,
 //     and arrays are represented using slices.
+//
+// Errors are either a `*SyntaxError` if the input is malformed,
+// or an `*EvalError` if it could not be evaluated.
+// Both include the position of the error in the input.
 func Evaluate(input string) (Evaluation, error) {
-	tokens := tokenize(input)
+	return EvaluateWithOptions(input, Options{})
+}
 
-	// basic validity checks
-	if len(tokens) < 2 {
-		return Evaluation{}, errors.New("token stream too short")
+// Options customise how an input is evaluated.
+type Options struct {
+	// Values for inputs supplied by the host application,
+	// keyed by input name with or without the leading `$`.
+	//
+	// Values are converted in the same way as `Marshal`,
+	// so may be any Go value that encodes to Corn.
+	// These take precedence over inputs declared in the `let` block,
+	// and can also provide inputs which are not declared at all.
+	Inputs map[string]any
+
+	// Looks up the environment variable for an `$env_` input,
+	// reporting whether it is set.
+	// A variable that is set, even to an empty string,
+	// takes precedence over the input declared in the `let` block.
+	//
+	// Defaults to `os.LookupEnv`.
+	// Use `MapEnv` to evaluate against a fixed set of variables.
+	LookupEnv func(name string) (string, bool)
+
+	// Restricts which environment variables `$env_` inputs can read.
+	// Referencing a variable outside the policy is an error,
+	// even if the input is declared in the `let` block.
+	//
+	// Defaults to nil, allowing any variable to be read.
+	// Use `&EnvPolicy{}` to disable environment variables entirely.
+	Env *EnvPolicy
+
+	// Strict reports common mistakes in the `let` block as errors before evaluating:
+	// inputs which are declared but never referenced from the object, directly or through other inputs,
+	// inputs which are declared more than once,
+	// and `$env_` inputs whose declaration is shadowed by a set environment variable.
+	//
+	// Each mistake is reported as an `*EvalError` with its position,
+	// combined using `errors.Join` if there are several.
+	Strict bool
+
+	// Merge enables deep merging of objects using the provided options.
+	// When an object spread or a key sets a value which is already present,
+	// objects are merged recursively instead of being replaced,
+	// so a nested key of a spread object can be overridden without repeating its siblings.
+	//
+	// Defaults to nil, where later values replace earlier ones.
+	Merge *MergeOptions
+
+	// Provenance records where each value in the result came from,
+	// in `Evaluation.Provenance`.
+	// This is intended for debugging, so is disabled by default.
+	Provenance bool
+
+	// Limits bound the resources used to compile and evaluate the input.
+	// Evaluation stops with an error wrapping `ErrLimitExceeded` as soon as one is exceeded.
+	//
+	// By default there are no limits, other than nesting being capped at `MaxNesting` levels
+	// so that deeply nested input returns an error rather than exhausting the stack.
+	// They should be set when evaluating untrusted input.
+	Limits Limits
+}
+
+// MapEnv returns a `LookupEnv` function
+// which reads environment variables from the provided map,
+// rather than the process environment.
+func MapEnv(env map[string]string) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
 	}
+}
 
-	if tokens[0].Id != tokenLet && tokens[0].Id != tokenBraceOpen {
-		return Evaluation{}, errors.New("expected first token to be one of `let` or `{`, got" + tokens[0].String())
+// An EnvPolicy lists the environment variables which `$env_` inputs may read.
+// Any variable not matching one of its fields is disallowed.
+type EnvPolicy struct {
+	// Names of variables which may be read, such as `HOME`.
+	Allow []string
+
+	// Prefixes of variables which may be read, such as `APP_`.
+	AllowPrefixes []string
+}
+
+// Reports whether the policy allows the environment variable to be read.
+// A nil policy allows every variable.
+func (policy *EnvPolicy) Allows(name string) bool {
+	if policy == nil {
+		return true
+	}
+
+	for _, allowed := range policy.Allow {
+		if name == allowed {
+			return true
+		}
 	}
 
-	if tokens[len(tokens)-1].Id != tokenBraceClose {
-		return Evaluation{}, errors.New("expected last token to be `}`, got " + tokens[len(tokens)-1].String())
+	for _, prefix := range policy.AllowPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
 	}
 
-	ast, err := parse(tokens)
+	return false
+}
+
+// Evaluates the input Corn string like `Evaluate`,
+// using the provided options.
+func EvaluateWithOptions(input string, options Options) (Evaluation, error) {
+	program, err := CompileWithLimits(input, options.Limits)
 
 	if err != nil {
 		return Evaluation{}, err
 	}
 
-	return evaluate(ast)
+	return program.Evaluate(options)
+}
+
+// A Program is a parsed Corn input,
+// which can be evaluated any number of times
+// without tokenizing and parsing it again.
+//
+// A Program is not modified by evaluation,
+// so it is safe to evaluate from multiple goroutines at once.
+type Program struct {
+	source string
+	ast    Rule[any]
+}
+
+// Compile tokenizes and parses the input Corn string,
+// returning a `*SyntaxError` if it is malformed.
+func Compile(input string) (*Program, error) {
+	return CompileWithLimits(input, Limits{})
+}
+
+// CompileWithLimits compiles the input like `Compile`,
+// returning an error wrapping `ErrLimitExceeded`
+// if it exceeds the byte, token or depth limits.
+//
+// The limits are not kept by the program,
+// so should also be passed to `Program.Evaluate` through `Options.Limits`.
+func CompileWithLimits(input string, limits Limits) (*Program, error) {
+	ast, err := compileWithLimits(input, limits)
+
+	if err != nil {
+		return nil, locateError(err, input)
+	}
+
+	return &Program{source: input, ast: ast}, nil
+}
+
+// Evaluate evaluates the program with the provided options.
+// See `Evaluate` for details of the result.
+func (program *Program) Evaluate(options Options) (Evaluation, error) {
+	evaluation, err := evaluate(program.ast, options)
+
+	for path, origin := range evaluation.Provenance {
+		origin.Position, _ = locate(program.source, origin.Span.Start)
+		evaluation.Provenance[path] = origin
+	}
+
+	return evaluation, locateError(err, program.source)
+}
+
+func compile(input string) (Rule[any], error) {
+	return compileWithLimits(input, Limits{})
+}
+
+func compileWithLimits(input string, limits Limits) (Rule[any], error) {
+	if limits.MaxBytes > 0 && len(input) > limits.MaxBytes {
+		return Rule[any]{}, limitError(Span{Start: limits.MaxBytes}, "input exceeds the limit of "+strconv.Itoa(limits.MaxBytes)+" bytes")
+	}
+
+	tokens, err := tokenize(input)
+
+	if err != nil {
+		return Rule[any]{}, err
+	}
+
+	err = checkSourceLimits(tokens, limits)
+
+	if err != nil {
+		return Rule[any]{}, err
+	}
+
+	// basic validity checks
+	if len(tokens) < 3 {
+		return Rule[any]{}, syntaxError(Span{Start: len(input)}, "token stream too short")
+	}
+
+	if tokens[0].Id != tokenLet && tokens[0].Id != tokenBraceOpen {
+		return Rule[any]{}, syntaxError(tokens[0].Span, "expected first token to be one of `let` or `{`, got "+tokens[0].String())
+	}
+
+	var last = tokens[len(tokens)-2]
+	if last.Id != tokenBraceClose {
+		return Rule[any]{}, syntaxError(last.Span, "expected last token to be `}`, got "+last.String())
+	}
+
+	return parse(tokens)
 }
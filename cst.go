@@ -0,0 +1,377 @@
+package corn
+
+import (
+	"strings"
+)
+
+// SyntaxKind identifies the type of a `SyntaxNode`.
+type SyntaxKind int
+
+const (
+	// Branch nodes, which group other nodes
+
+	SyntaxConfig SyntaxKind = iota
+	SyntaxAssignBlock
+	SyntaxAssignment
+	SyntaxObject
+	SyntaxPair
+	SyntaxPath
+	SyntaxSpread
+	SyntaxArray
+	SyntaxString
+
+	// Leaf nodes, which each hold a single token
+
+	SyntaxPunct // one of `{`, `}`, `[`, `]`, `=`, `.`, `..`, `"`, `let` or `in`
+	SyntaxPathSegment
+	SyntaxInput
+	SyntaxBoolean
+	SyntaxInteger
+	SyntaxFloat
+	SyntaxNull
+	SyntaxCharSequence
+	SyntaxCharEscape
+
+	// Trivia leaf nodes
+
+	SyntaxWhitespace
+	SyntaxComment
+)
+
+func (kind SyntaxKind) String() string {
+	switch kind {
+	case SyntaxConfig:
+		return "Config"
+	case SyntaxAssignBlock:
+		return "AssignBlock"
+	case SyntaxAssignment:
+		return "Assignment"
+	case SyntaxObject:
+		return "Object"
+	case SyntaxPair:
+		return "Pair"
+	case SyntaxPath:
+		return "Path"
+	case SyntaxSpread:
+		return "Spread"
+	case SyntaxArray:
+		return "Array"
+	case SyntaxString:
+		return "String"
+	case SyntaxPunct:
+		return "Punct"
+	case SyntaxPathSegment:
+		return "PathSegment"
+	case SyntaxInput:
+		return "Input"
+	case SyntaxBoolean:
+		return "Boolean"
+	case SyntaxInteger:
+		return "Integer"
+	case SyntaxFloat:
+		return "Float"
+	case SyntaxNull:
+		return "Null"
+	case SyntaxCharSequence:
+		return "CharSequence"
+	case SyntaxCharEscape:
+		return "CharEscape"
+	case SyntaxWhitespace:
+		return "Whitespace"
+	case SyntaxComment:
+		return "Comment"
+	default:
+		return "?"
+	}
+}
+
+// A SyntaxNode is a node in a lossless concrete syntax tree.
+//
+// Branch nodes have `Children`, while leaf nodes hold the exact `Text`
+// of a single token, comment or run of whitespace.
+// Every byte of the source belongs to exactly one leaf,
+// so concatenating the leaves in order reproduces the source.
+//
+// Whitespace and comments are attached to the innermost node open at the time,
+// so leading trivia belongs to the enclosing node rather than the node that follows it.
+type SyntaxNode struct {
+	Kind     SyntaxKind
+	Span     Span
+	Text     string
+	Children []*SyntaxNode
+}
+
+// IsTrivia reports whether the node is whitespace or a comment.
+func (node *SyntaxNode) IsTrivia() bool {
+	return node.Kind == SyntaxWhitespace || node.Kind == SyntaxComment
+}
+
+// IsLeaf reports whether the node holds a single token rather than other nodes.
+func (node *SyntaxNode) IsLeaf() bool {
+	return node.Kind >= SyntaxPunct
+}
+
+// String returns the source text covered by the node,
+// including any whitespace and comments within it.
+func (node *SyntaxNode) String() string {
+	sb := new(strings.Builder)
+	node.write(sb)
+	return sb.String()
+}
+
+func (node *SyntaxNode) write(sb *strings.Builder) {
+	if node.IsLeaf() {
+		sb.WriteString(node.Text)
+		return
+	}
+
+	for _, child := range node.Children {
+		child.write(sb)
+	}
+}
+
+// Inspect traverses the tree in depth-first order,
+// calling `f` for each node.
+// If `f` returns false, the children of that node are skipped.
+func (node *SyntaxNode) Inspect(f func(*SyntaxNode) bool) {
+	if !f(node) {
+		return
+	}
+
+	for _, child := range node.Children {
+		child.Inspect(f)
+	}
+}
+
+// ParseCST parses the input into a lossless concrete syntax tree,
+// returning the root `SyntaxConfig` node.
+//
+// Unlike `Compile`, every token, comment and run of whitespace is kept,
+// so `String` on the root reproduces the input byte-for-byte.
+// A `*SyntaxError` is returned if the input is not valid Corn.
+func ParseCST(input string) (*SyntaxNode, error) {
+	_, err := compile(input)
+
+	if err != nil {
+		return nil, locateError(err, input)
+	}
+
+	tokens, err := scan(input, scanComments|scanWhitespace)
+
+	if err != nil {
+		return nil, locateError(err, input)
+	}
+
+	b := &cstBuilder{source: input, tokens: tokens}
+	b.stack = []*SyntaxNode{{Kind: SyntaxConfig}}
+
+	b.buildConfig()
+
+	// any remaining trivia belongs to the root
+	for b.tokens[b.pos].Id != tokenEOF {
+		b.leaf()
+	}
+
+	root := b.stack[0]
+	root.Span = Span{Start: 0, End: len(input)}
+
+	return root, nil
+}
+
+// Builds a syntax tree from a lossless token stream.
+//
+// The input has already been validated by the parser,
+// so the builder assumes the tokens are well-formed.
+type cstBuilder struct {
+	source string
+	tokens []Token[any]
+	pos    int
+	stack  []*SyntaxNode
+}
+
+func (b *cstBuilder) current() *SyntaxNode {
+	return b.stack[len(b.stack)-1]
+}
+
+// Returns the next token which is not trivia.
+func (b *cstBuilder) peek() Token[any] {
+	for i := b.pos; i < len(b.tokens); i++ {
+		if b.tokens[i].Id != tokenComment && b.tokens[i].Id != tokenWhitespace {
+			return b.tokens[i]
+		}
+	}
+
+	return b.tokens[len(b.tokens)-1]
+}
+
+// Adds any trivia up to the next token to the current node.
+func (b *cstBuilder) trivia() {
+	for b.tokens[b.pos].Id == tokenComment || b.tokens[b.pos].Id == tokenWhitespace {
+		b.leaf()
+	}
+}
+
+// Adds the next token to the current node as a leaf.
+func (b *cstBuilder) leaf() {
+	token := b.tokens[b.pos]
+	b.pos++
+
+	node := &SyntaxNode{
+		Kind: leafKind(token.Id),
+		Span: token.Span,
+		Text: b.source[token.Span.Start:token.Span.End],
+	}
+
+	parent := b.current()
+	parent.Children = append(parent.Children, node)
+}
+
+// Adds any leading trivia to the current node,
+// then opens a new child node of the given kind.
+func (b *cstBuilder) start(kind SyntaxKind) {
+	b.trivia()
+
+	node := &SyntaxNode{Kind: kind, Span: Span{Start: b.tokens[b.pos].Span.Start}}
+
+	parent := b.current()
+	parent.Children = append(parent.Children, node)
+
+	b.stack = append(b.stack, node)
+}
+
+// Closes the current node, which ends at the last token added to it.
+func (b *cstBuilder) finish() {
+	node := b.current()
+	node.Span.End = b.tokens[b.pos-1].Span.End
+
+	b.stack = b.stack[:len(b.stack)-1]
+}
+
+// Adds any trivia followed by the next token to the current node.
+func (b *cstBuilder) token() {
+	b.trivia()
+	b.leaf()
+}
+
+func leafKind(id tokenId) SyntaxKind {
+	switch id {
+	case tokenPathSegment:
+		return SyntaxPathSegment
+	case tokenInput:
+		return SyntaxInput
+	case tokenTrue, tokenFalse:
+		return SyntaxBoolean
+	case tokenInteger:
+		return SyntaxInteger
+	case tokenFloat:
+		return SyntaxFloat
+	case tokenNull:
+		return SyntaxNull
+	case tokenCharSequence:
+		return SyntaxCharSequence
+	case tokenCharEscape:
+		return SyntaxCharEscape
+	case tokenWhitespace:
+		return SyntaxWhitespace
+	case tokenComment:
+		return SyntaxComment
+	default:
+		return SyntaxPunct
+	}
+}
+
+func (b *cstBuilder) buildConfig() {
+	if b.peek().Id == tokenLet {
+		b.token() // let
+
+		b.start(SyntaxAssignBlock)
+		b.token() // {
+
+		for b.peek().Id == tokenInput {
+			b.start(SyntaxAssignment)
+			b.token() // input
+			b.token() // =
+			b.buildValue()
+			b.finish()
+		}
+
+		b.token() // }
+		b.token() // in
+		b.finish()
+	}
+
+	b.buildObject()
+}
+
+func (b *cstBuilder) buildValue() {
+	switch b.peek().Id {
+	case tokenBraceOpen:
+		b.buildObject()
+	case tokenBracketOpen:
+		b.buildArray()
+	case tokenDoubleQuote:
+		b.start(SyntaxString)
+		b.token() // "
+
+		// strings never contain trivia tokens
+		for b.tokens[b.pos].Id != tokenDoubleQuote {
+			b.leaf()
+		}
+
+		b.leaf() // "
+		b.finish()
+	default:
+		b.token()
+	}
+}
+
+func (b *cstBuilder) buildSpread() {
+	b.start(SyntaxSpread)
+	b.token() // ..
+	b.token() // input
+	b.finish()
+}
+
+func (b *cstBuilder) buildObject() {
+	b.start(SyntaxObject)
+	b.token() // {
+
+	for b.peek().Id != tokenBraceClose {
+		if b.peek().Id == tokenSpread {
+			b.buildSpread()
+			continue
+		}
+
+		b.start(SyntaxPair)
+		b.start(SyntaxPath)
+
+		for b.peek().Id == tokenPathSegment || b.peek().Id == tokenPathSeparator {
+			b.token()
+		}
+
+		b.finish()
+
+		b.token() // =
+		b.buildValue()
+		b.finish()
+	}
+
+	b.token() // }
+	b.finish()
+}
+
+func (b *cstBuilder) buildArray() {
+	b.start(SyntaxArray)
+	b.token() // [
+
+	for b.peek().Id != tokenBracketClose {
+		if b.peek().Id == tokenSpread {
+			b.buildSpread()
+		} else {
+			b.buildValue()
+		}
+	}
+
+	b.token() // ]
+	b.finish()
+}
@@ -0,0 +1,117 @@
+package corn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var cstInputs = []string{
+	"{}",
+	"  \n{ a = 1 }\n\n",
+	"// header\r\nlet {\r\n\t$a = 1 // one\r\n} in {\r\n\tfoo.'b c' = [ $a ..$arr ] // two\r\n}\r\n// footer",
+	"{ a = \"multi\n   line $x \\n \\u00e9\" b=\"\" c =  { } }",
+	"{ ..$obj a.b.c = -1.5e3 d = 0x1f e = null f = true }",
+	"{ a = \"\xff\" }\n\u00a0",
+}
+
+func TestParseCST(t *testing.T) {
+	for _, input := range cstInputs {
+		root, err := ParseCST(input)
+
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", input, err)
+			continue
+		}
+
+		assertEqual(t, root.String(), input)
+		checkSpans(t, input, root)
+	}
+}
+
+func TestParseCSTStructure(t *testing.T) {
+	root, err := ParseCST("let { $a = 1 } in {\n    // note\n    foo = \"x $a\"\n}")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	var comment string
+
+	root.Inspect(func(node *SyntaxNode) bool {
+		if node.Kind == SyntaxComment {
+			comment = node.Text
+		}
+
+		if !node.IsLeaf() {
+			kinds = append(kinds, node.Kind.String())
+		}
+
+		return node.Kind != SyntaxString
+	})
+
+	assertEqual(t, comment, "// note")
+	assertEqual(t, strings.Join(kinds, " "), "Config AssignBlock Assignment Object Pair Path String")
+}
+
+func TestParseCSTInvalid(t *testing.T) {
+	for _, input := range malformedInputs {
+		_, err := ParseCST(input)
+		_, compileErr := Compile(input)
+
+		if (err == nil) != (compileErr == nil) {
+			t.Errorf("expected ParseCST and Compile to agree for %q, got %v and %v", input, err, compileErr)
+		}
+
+		var syntaxErr *SyntaxError
+		if err != nil && !errors.As(err, &syntaxErr) {
+			t.Errorf("expected SyntaxError for %q, got %T: %v", input, err, err)
+		}
+	}
+}
+
+func FuzzParseCST(f *testing.F) {
+	for _, input := range cstInputs {
+		f.Add(input)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		root, err := ParseCST(input)
+
+		if err != nil {
+			return
+		}
+
+		if root.String() != input {
+			t.Fatalf("round trip of %q produced %q", input, root.String())
+		}
+
+		checkSpans(t, input, root)
+	})
+}
+
+// Checks that leaves cover the input contiguously and match their spans.
+func checkSpans(t *testing.T, input string, root *SyntaxNode) {
+	offset := 0
+
+	root.Inspect(func(node *SyntaxNode) bool {
+		if node.Span.Start > node.Span.End || node.Span.End > len(input) {
+			t.Fatalf("invalid span %+v for %s in %q", node.Span, node.Kind, input)
+		}
+
+		if node.IsLeaf() {
+			if node.Span.Start != offset || input[node.Span.Start:node.Span.End] != node.Text {
+				t.Fatalf("leaf %s %q at %+v does not follow offset %d in %q", node.Kind, node.Text, node.Span, offset, input)
+			}
+
+			offset = node.Span.End
+		}
+
+		return true
+	})
+
+	if offset != len(input) {
+		t.Fatalf("leaves end at %d, expected %d in %q", offset, len(input), input)
+	}
+}
@@ -0,0 +1,62 @@
+package corn
+
+import (
+	"io"
+	"strings"
+)
+
+// A Decoder reads and decodes a Corn document from an input stream.
+type Decoder struct {
+	r    io.Reader
+	done bool
+}
+
+// NewDecoder returns a new decoder that reads from `r`.
+//
+// A Corn document can only be evaluated once it has been read in full,
+// so the decoder consumes `r` until EOF on the first call to `Decode`.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the Corn document from its input,
+// evaluates it and stores the result in the value pointed to by `v`.
+//
+// See `Unmarshal` for details about the conversion into a Go value.
+// Once the document has been decoded, or if the input is empty,
+// subsequent calls return `io.EOF`.
+func (dec *Decoder) Decode(v any) error {
+	evaluation, err := dec.Evaluate()
+
+	if err != nil {
+		return err
+	}
+
+	return evaluation.Unmarshal(v)
+}
+
+// Evaluate reads the Corn document from its input
+// and returns the resulting `Evaluation`.
+//
+// Like `Decode`, it returns `io.EOF` once the input has been consumed.
+func (dec *Decoder) Evaluate() (Evaluation, error) {
+	if dec.done {
+		return Evaluation{}, io.EOF
+	}
+
+	dec.done = true
+
+	data, err := io.ReadAll(dec.r)
+
+	if err != nil {
+		return Evaluation{}, err
+	}
+
+	input := string(data)
+
+	if strings.TrimSpace(input) == "" {
+		return Evaluation{}, io.EOF
+	}
+
+	return Evaluate(input)
+}
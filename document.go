@@ -0,0 +1,554 @@
+package corn
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultIndent = "    "
+
+// A Document is a Corn source file which can be edited programmatically.
+//
+// Edits rewrite only the affected region of the source,
+// so comments, whitespace, key order and `let` bindings elsewhere are left as they are.
+// Paths use the same syntax as `Evaluation.Get`,
+// and refer to keys as they are written in the source rather than the evaluated result,
+// so values supplied by inputs or spreads cannot be edited.
+type Document struct {
+	source string
+	root   *SyntaxNode
+
+	// indent of each level of nesting, detected from the source
+	indent string
+	// line break used by the source
+	newline string
+}
+
+// ParseDocument parses the input into a `Document` for editing.
+// A `*SyntaxError` is returned if the input is not valid Corn.
+func ParseDocument(input string) (*Document, error) {
+	root, err := ParseCST(input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{source: input, root: root, indent: defaultIndent, newline: "\n"}
+
+	if strings.Contains(input, "\r\n") {
+		doc.newline = "\r\n"
+	}
+
+	object := doc.object()
+	items := syntaxEntries(object)
+
+	if len(items) > 0 && doc.lineStart(items[0].Span.Start) > object.Span.Start {
+		if indent := doc.lineIndent(items[0].Span.Start); indent != "" {
+			doc.indent = indent
+		}
+	}
+
+	return doc, nil
+}
+
+// String returns the current source of the document.
+func (doc *Document) String() string {
+	return doc.source
+}
+
+// Set assigns the value at the dotted path.
+//
+// If the path is already assigned in the source, the last assignment to it is replaced.
+// Otherwise a new key is added to the innermost object in the source which contains the path.
+// Numeric segments index into arrays written in the source.
+// An error is returned if the path passes through a string, number, boolean or null.
+// The value is converted in the same way as `Marshal`.
+func (doc *Document) Set(path string, v any) error {
+	segments, err := splitPath(path)
+
+	if err != nil {
+		return err
+	}
+
+	value, err := valueOf(v)
+
+	if err != nil {
+		return err
+	}
+
+	loc, err := doc.locate(segments)
+
+	if err != nil {
+		return err
+	}
+
+	if loc.value != nil {
+		text, err := doc.render(value, doc.lineIndent(loc.value.Span.Start), doc.isInline(loc.container))
+
+		if err != nil {
+			return err
+		}
+
+		return doc.apply(edit{start: loc.value.Span.Start, end: loc.value.Span.End, text: text})
+	}
+
+	key, err := quotePath(loc.rest)
+
+	if err != nil {
+		return err
+	}
+
+	ins := doc.insertion(loc.container)
+	text, err := doc.render(value, ins.prefix, ins.compact)
+
+	if err != nil {
+		return err
+	}
+
+	return doc.apply(edit{start: ins.start, end: ins.end, text: ins.before + key + " = " + text + ins.after})
+}
+
+// Delete removes the value at the dotted path.
+//
+// Every assignment to the path or to keys below it is removed,
+// along with any comment on the same line.
+// Numeric segments index into arrays written in the source, as for `Set`,
+// and remove a single element when they end the path.
+// An error is returned if nothing in the source assigns the path.
+func (doc *Document) Delete(path string) error {
+	segments, err := splitPath(path)
+
+	if err != nil {
+		return err
+	}
+
+	loc, err := doc.locate(segments)
+
+	if err != nil {
+		return err
+	}
+
+	if loc.entry != nil && loc.container.Kind == SyntaxArray {
+		return doc.apply(doc.removal(loc.entry))
+	}
+
+	var edits []edit
+
+	// `locate` has checked that the arrays along the path can be indexed,
+	// but those replaced by later assignments may be shorter or contain spreads
+	var walk func(node *SyntaxNode, base []string)
+	walk = func(node *SyntaxNode, base []string) {
+		switch node.Kind {
+		case SyntaxObject:
+			for _, pair := range syntaxEntries(node) {
+				if pair.Kind != SyntaxPair {
+					continue
+				}
+
+				keys := append(append([]string{}, base...), pairKeys(pair)...)
+
+				if hasPrefix(keys, segments) {
+					edits = append(edits, doc.removal(pair))
+				} else if hasPrefix(segments, keys) {
+					walk(pair.Children[len(pair.Children)-1], keys)
+				}
+			}
+		case SyntaxArray:
+			// a whole element is removed above, so this only descends into one
+			elements := syntaxEntries(node)
+			index, _ := strconv.Atoi(segments[len(base)])
+
+			if len(base)+1 == len(segments) || index >= len(elements) {
+				return
+			}
+
+			for _, element := range elements {
+				if element.Kind == SyntaxSpread {
+					return
+				}
+			}
+
+			walk(elements[index], append(append([]string{}, base...), segments[len(base)]))
+		}
+	}
+
+	walk(doc.object(), nil)
+
+	if len(edits) == 0 {
+		return errors.New("key `" + path + "` is not assigned in the document")
+	}
+
+	return doc.apply(edits...)
+}
+
+// Append adds the value to the end of the array at the dotted path.
+// The array must be written as a literal in the source.
+// The value is converted in the same way as `Marshal`.
+func (doc *Document) Append(path string, v any) error {
+	segments, err := splitPath(path)
+
+	if err != nil {
+		return err
+	}
+
+	value, err := valueOf(v)
+
+	if err != nil {
+		return err
+	}
+
+	loc, err := doc.locate(segments)
+
+	if err != nil {
+		return err
+	}
+
+	if loc.value == nil {
+		return errors.New("key `" + path + "` is not assigned in the document")
+	}
+
+	if loc.value.Kind != SyntaxArray {
+		return errors.New("cannot append to " + strings.ToLower(loc.value.Kind.String()) + " at `" + path + "`, expected array")
+	}
+
+	ins := doc.insertion(loc.value)
+	text, err := doc.render(value, ins.prefix, ins.compact)
+
+	if err != nil {
+		return err
+	}
+
+	return doc.apply(edit{start: ins.start, end: ins.end, text: ins.before + text + ins.after})
+}
+
+// Returns the top-level object of the document.
+func (doc *Document) object() *SyntaxNode {
+	for _, child := range doc.root.Children {
+		if child.Kind == SyntaxObject {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// The result of resolving a path against the source.
+type location struct {
+	// innermost object or array in the source containing the path
+	container *SyntaxNode
+	// pair or array element which assigns the path, if any
+	entry *SyntaxNode
+	// value of the entry, if any
+	value *SyntaxNode
+	// segments below the container which are not assigned in the source
+	rest []string
+}
+
+// Resolves the path to the last entry in the source which assigns it,
+// or the innermost object which contains it if there is none.
+func (doc *Document) locate(segments []string) (location, error) {
+	loc := location{container: doc.object(), rest: segments}
+
+	for {
+		var entry *SyntaxNode
+		var value *SyntaxNode
+		var consumed int
+
+		switch loc.container.Kind {
+		case SyntaxObject:
+			for _, child := range syntaxEntries(loc.container) {
+				if child.Kind != SyntaxPair {
+					continue
+				}
+
+				keys := pairKeys(child)
+
+				if hasPrefix(loc.rest, keys) {
+					entry = child
+					consumed = len(keys)
+				}
+			}
+
+			if entry == nil {
+				return loc, nil
+			}
+
+			value = entry.Children[len(entry.Children)-1]
+		case SyntaxArray:
+			parent := joinPath(segments[:len(segments)-len(loc.rest)])
+			elements := syntaxEntries(loc.container)
+
+			for _, element := range elements {
+				if element.Kind == SyntaxSpread {
+					return loc, errors.New("cannot index into array at `" + parent + "` containing a spread")
+				}
+			}
+
+			index, err := strconv.Atoi(loc.rest[0])
+
+			if err != nil || index < 0 || index >= len(elements) {
+				return loc, errors.New("invalid index `" + loc.rest[0] + "` into array at `" + parent + "` of length " + strconv.Itoa(len(elements)))
+			}
+
+			entry = elements[index]
+			value = entry
+			consumed = 1
+		}
+
+		if consumed == len(loc.rest) {
+			loc.entry = entry
+			loc.value = value
+			loc.rest = nil
+			return loc, nil
+		}
+
+		// keys below inputs are added alongside them in the current object,
+		// since the input may evaluate to an object
+		if value.Kind == SyntaxInput {
+			return loc, nil
+		}
+
+		if value.Kind != SyntaxObject && value.Kind != SyntaxArray {
+			at := joinPath(segments[:len(segments)-len(loc.rest)+consumed])
+			return loc, errors.New("path `" + joinPath(segments) + "` passes through " + strings.ToLower(value.Kind.String()) + " at `" + at + "`")
+		}
+
+		loc.container = value
+		loc.rest = loc.rest[consumed:]
+	}
+}
+
+// Returns the entries of an object or array node,
+// excluding its brackets and any trivia.
+func syntaxEntries(node *SyntaxNode) []*SyntaxNode {
+	var entries []*SyntaxNode
+
+	for _, child := range node.Children[1 : len(node.Children)-1] {
+		if !child.IsTrivia() {
+			entries = append(entries, child)
+		}
+	}
+
+	return entries
+}
+
+// Returns the unquoted keys of a pair's path.
+func pairKeys(pair *SyntaxNode) []string {
+	var keys []string
+
+	for _, child := range pair.Children[0].Children {
+		if child.Kind != SyntaxPathSegment {
+			continue
+		}
+
+		key := child.Text
+
+		if strings.HasPrefix(key, "'") {
+			key = strings.ReplaceAll(key[1:len(key)-1], "\\'", "'")
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func hasPrefix(segments []string, prefix []string) bool {
+	if len(prefix) > len(segments) {
+		return false
+	}
+
+	for i := range prefix {
+		if segments[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Joins path segments into a path as written in source, quoting where required.
+func quotePath(segments []string) (string, error) {
+	parts := make([]string, len(segments))
+
+	for i, seg := range segments {
+		quoted, err := quotePathSegment(seg)
+
+		if err != nil {
+			return "", err
+		}
+
+		parts[i] = quoted
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// Returns whether the node's brackets are on the same line.
+func (doc *Document) isInline(node *SyntaxNode) bool {
+	return !strings.Contains(doc.source[node.Span.Start:node.Span.End], "\n")
+}
+
+func (doc *Document) lineStart(offset int) int {
+	return strings.LastIndexByte(doc.source[:offset], '\n') + 1
+}
+
+// Returns the offset of the line break ending the line containing `offset`,
+// or the end of the source if there is none.
+func (doc *Document) lineEnd(offset int) int {
+	end := strings.IndexByte(doc.source[offset:], '\n')
+
+	if end < 0 {
+		return len(doc.source)
+	}
+
+	end += offset
+
+	if end > 0 && doc.source[end-1] == '\r' {
+		end--
+	}
+
+	return end
+}
+
+// Returns the whitespace at the start of the line containing `offset`.
+func (doc *Document) lineIndent(offset int) string {
+	line := doc.source[doc.lineStart(offset):]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// Writes a value as source text,
+// with each line after the first starting with `prefix`.
+func (doc *Document) render(value Value, prefix string, compact bool) (string, error) {
+	enc := &encoder{compact: compact, prefix: prefix, indent: doc.indent}
+	err := enc.writeValue(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(enc.sb.String(), "\n", doc.newline), nil
+}
+
+// Describes where to write a new entry within an object or array.
+type insertion struct {
+	start, end int
+	// text to write before and after the entry
+	before, after string
+	// indent of the entry's line
+	prefix string
+	// whether the entry must fit on a single line
+	compact bool
+}
+
+// Returns where a new entry is written at the end of the object or array,
+// following the layout of its existing entries.
+func (doc *Document) insertion(container *SyntaxNode) insertion {
+	open := container.Children[0]
+	close := container.Children[len(container.Children)-1]
+	items := syntaxEntries(container)
+
+	if doc.isInline(container) {
+		if len(items) == 0 {
+			return insertion{start: open.Span.End, end: close.Span.Start, before: " ", after: " ", compact: true}
+		}
+
+		last := items[len(items)-1].Span.End
+		return insertion{start: last, end: last, before: " ", compact: true}
+	}
+
+	if len(items) == 0 {
+		lineStart := doc.lineStart(close.Span.Start)
+		prefix := doc.source[lineStart:close.Span.Start] + doc.indent
+
+		return insertion{start: lineStart, end: lineStart, before: prefix, after: doc.newline, prefix: prefix}
+	}
+
+	last := items[len(items)-1]
+	prefix := doc.lineIndent(last.Span.Start)
+	lineEnd := doc.lineEnd(last.Span.End)
+
+	// the closing bracket is on the same line as the last entry
+	if lineEnd > close.Span.Start {
+		return insertion{start: last.Span.End, end: last.Span.End, before: " ", prefix: prefix}
+	}
+
+	return insertion{start: lineEnd, end: lineEnd, before: doc.newline + prefix, prefix: prefix}
+}
+
+// A replacement of the source between two offsets.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Returns the edit which removes an entry from its object or array.
+//
+// Entries on their own line are removed along with the line and any comment at the end of it,
+// otherwise the whitespace separating the entry from its neighbour is removed.
+func (doc *Document) removal(entry *SyntaxNode) edit {
+	start, end := entry.Span.Start, entry.Span.End
+
+	lineStart := doc.lineStart(start)
+	lineEnd := doc.lineEnd(end)
+
+	before := strings.TrimLeft(doc.source[lineStart:start], " \t")
+	after := strings.TrimLeft(doc.source[end:lineEnd], " \t")
+
+	if before == "" && (after == "" || strings.HasPrefix(after, "//")) {
+		if strings.HasPrefix(doc.source[lineEnd:], "\r\n") {
+			lineEnd += 2
+		} else if lineEnd < len(doc.source) {
+			lineEnd++
+		}
+
+		return edit{start: lineStart, end: lineEnd}
+	}
+
+	if before == "" {
+		return edit{start: start, end: len(doc.source) - len(strings.TrimLeft(doc.source[end:], " \t"))}
+	}
+
+	return edit{start: len(strings.TrimRight(doc.source[:start], " \t")), end: end}
+}
+
+// Applies the edits to the source and parses the result.
+// Overlapping removals are merged.
+func (doc *Document) apply(edits ...edit) error {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	sb := new(strings.Builder)
+	offset := 0
+
+	for _, e := range edits {
+		if e.start < offset {
+			e.start = offset
+		}
+
+		if e.end < e.start {
+			e.end = e.start
+		}
+
+		sb.WriteString(doc.source[offset:e.start])
+		sb.WriteString(e.text)
+		offset = e.end
+	}
+
+	sb.WriteString(doc.source[offset:])
+
+	source := sb.String()
+	root, err := ParseCST(source)
+
+	if err != nil {
+		return errors.New("edit produced invalid source: " + err.Error())
+	}
+
+	doc.source = source
+	doc.root = root
+
+	return nil
+}
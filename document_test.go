@@ -0,0 +1,181 @@
+package corn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	input := `// release config
+let {
+    $base = { debug = false }
+} in {
+    name = "app" // the app name
+    version = "1.0.0"
+
+    server = {
+        host = "localhost"
+        port = 80
+    }
+
+    features.beta = true // experimental
+    features.alpha = false
+    hosts = [ "a" "b" ]
+    empty = {}
+    ..$base
+}
+`
+
+	expected := `// release config
+let {
+    $base = { debug = false }
+} in {
+    name = "app" // the app name
+    version = "1.1.0"
+
+    server = {
+        host = "localhost"
+        port = 8080
+        tls = {
+            enabled = true
+        }
+    }
+
+    features.alpha = false
+    hosts = [ "a" "b" "x" ]
+    empty = { a = [ 1 2 ] }
+    ..$base
+    'new key' = null
+}
+`
+
+	doc, err := ParseDocument(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tls := map[string]any{"enabled": true}
+
+	steps := []func() error{
+		func() error { return doc.Set("version", "1.1.0") },
+		func() error { return doc.Set("server.port", 8080) },
+		func() error { return doc.Set("server.tls", tls) },
+		func() error { return doc.Delete("features.beta") },
+		func() error { return doc.Append("hosts", "x") },
+		func() error { return doc.Set("empty.a", []int{1, 2}) },
+		func() error { return doc.Set("'new key'", nil) },
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertEqual(t, doc.String(), expected)
+
+	evaluation, err := Evaluate(doc.String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port, _ := evaluation.Get("server.port")
+
+	if port != int64(8080) {
+		t.Fatalf("expected port 8080, got %v", port)
+	}
+}
+
+func TestDocumentLayout(t *testing.T) {
+	tests := []struct {
+		input    string
+		edit     func(doc *Document) error
+		expected string
+	}{
+		{
+			"{\r\n\tarr = [\r\n\t\t1\r\n\t\t2 // two\r\n\t]\r\n}",
+			func(doc *Document) error { return doc.Append("arr", map[string]int{"a": 1}) },
+			"{\r\n\tarr = [\r\n\t\t1\r\n\t\t2 // two\r\n\t\t{\r\n\t\t\ta = 1\r\n\t\t}\r\n\t]\r\n}",
+		},
+		{
+			"{\n    arr = [\n        1\n        2 // two\n    ]\n}",
+			func(doc *Document) error { return doc.Delete("arr.1") },
+			"{\n    arr = [\n        1\n    ]\n}",
+		},
+		{
+			"{\n    // items\n    a = [\n        {\n            b = 1 // one\n            c = 2 // two\n        }\n        { b = 3 c = 4 }\n    ]\n}",
+			func(doc *Document) error {
+				return errors.Join(doc.Delete("a.0.b"), doc.Delete("a.1.c"))
+			},
+			"{\n    // items\n    a = [\n        {\n            c = 2 // two\n        }\n        { b = 3 }\n    ]\n}",
+		},
+		{
+			"{ a = 1 b.c = 2 b.d = 3 }",
+			func(doc *Document) error { return doc.Delete("b") },
+			"{ a = 1 }",
+		},
+		{
+			"{\n    a = { b = 1 }\n    a.c = 2\n}",
+			func(doc *Document) error { return doc.Set("a.b", "x") },
+			"{\n    a = { b = \"x\" }\n    a.c = 2\n}",
+		},
+		{
+			"{\n    a = $in\n}",
+			func(doc *Document) error { return doc.Set("a.b", 1) },
+			"{\n    a = $in\n    a.b = 1\n}",
+		},
+		{
+			"{\n    a = 1\n    a = 2\n}",
+			func(doc *Document) error { return doc.Delete("a") },
+			"{\n}",
+		},
+		{
+			"{\n}",
+			func(doc *Document) error { return doc.Set("a", 1.5) },
+			"{\n    a = 1.5\n}",
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := ParseDocument(test.input)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := test.edit(doc); err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.input, err)
+		}
+
+		assertEqual(t, doc.String(), test.expected)
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	doc, err := ParseDocument("{ a = 1 arr = [ ..$b 1 ] }")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := []error{
+		doc.Delete("missing"),
+		doc.Append("a", 2),
+		doc.Append("missing", 2),
+		doc.Set("arr.0", 2),
+		doc.Set("a", func() {}),
+		doc.Set("a..b", 1),
+		doc.Set("a.b", 2),
+	}
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("expected error for case %d", i)
+		}
+	}
+
+	assertEqual(t, doc.String(), "{ a = 1 arr = [ ..$b 1 ] }")
+	assertEqual(t, errs[len(errs)-1].Error(), "path `a.b` passes through integer at `a`")
+}
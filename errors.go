@@ -0,0 +1,163 @@
+package corn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Span is a range of bytes in the source text,
+// starting at `Start` (inclusive) and ending at `End` (exclusive).
+type Span struct {
+	Start int
+	End   int
+}
+
+// A Position describes a location in the source text.
+//
+// `Offset` is the zero-based byte offset,
+// while `Line` and `Column` are one-based,
+// with columns counted in characters.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (pos Position) String() string {
+	return strconv.Itoa(pos.Line) + ":" + strconv.Itoa(pos.Column)
+}
+
+// A SyntaxError is returned when the input cannot be tokenized or parsed.
+type SyntaxError struct {
+	Position
+	Msg string
+
+	// The offending source line, followed by a line with a caret under the error position.
+	Snippet string
+}
+
+func (e *SyntaxError) Error() string {
+	return formatError(e.Position, e.Msg)
+}
+
+// An EvalError is returned when a syntactically valid input cannot be evaluated,
+// for example because it references an input which does not exist.
+type EvalError struct {
+	Position
+	Msg string
+
+	// The offending source line, followed by a line with a caret under the error position.
+	Snippet string
+
+	// the underlying cause, such as `ErrLimitExceeded`
+	err error
+}
+
+func (e *EvalError) Error() string {
+	return formatError(e.Position, e.Msg)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.err
+}
+
+// An InputError is returned when a value supplied through `Options.Inputs` is invalid.
+// Unlike other errors it has no position, as the value does not come from the source.
+type InputError struct {
+	// The name of the input, including the `$`.
+	Name string
+	Msg  string
+}
+
+func (e *InputError) Error() string {
+	return "invalid input `" + e.Name + "`: " + e.Msg
+}
+
+func formatError(pos Position, msg string) string {
+	if pos.Line == 0 {
+		return msg
+	}
+
+	return pos.String() + ": " + msg
+}
+
+func syntaxError(span Span, msg string) *SyntaxError {
+	return &SyntaxError{Position: Position{Offset: span.Start}, Msg: msg}
+}
+
+func evalError(span Span, msg string) *EvalError {
+	return &EvalError{Position: Position{Offset: span.Start}, Msg: msg}
+}
+
+// Fills in the line, column and snippet of a `SyntaxError` or `EvalError`
+// from the source text it was raised for.
+//
+// Errors are created with only an offset,
+// as the tokenizer, parser and evaluator do not have access to the source.
+// Each error combined by `errors.Join` is located.
+func locateError(err error, source string) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			locateError(err, source)
+		}
+
+		return err
+	}
+
+	var syntaxErr *SyntaxError
+	var evalErr *EvalError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		syntaxErr.Position, syntaxErr.Snippet = locate(source, syntaxErr.Offset)
+	case errors.As(err, &evalErr):
+		evalErr.Position, evalErr.Snippet = locate(source, evalErr.Offset)
+	}
+
+	return err
+}
+
+// Returns the position and a rendered snippet for a byte offset into the source.
+func locate(source string, offset int) (Position, string) {
+	offset = max(0, min(offset, len(source)))
+
+	lineStart := strings.LastIndexByte(source[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(source[offset:], '\n')
+
+	if lineEnd == -1 {
+		lineEnd = len(source)
+	} else {
+		lineEnd += offset
+	}
+
+	line := strings.TrimSuffix(source[lineStart:lineEnd], "\r")
+	prefix := source[lineStart:offset]
+
+	pos := Position{
+		Offset: offset,
+		Line:   strings.Count(source[:offset], "\n") + 1,
+		Column: utf8.RuneCountInString(prefix) + 1,
+	}
+
+	lineNum := strconv.Itoa(pos.Line)
+	gutter := strings.Repeat(" ", len(lineNum))
+
+	sb := new(strings.Builder)
+	sb.WriteString(lineNum + " | " + line + "\n")
+	sb.WriteString(gutter + " | ")
+
+	// keep tabs so the caret lines up with the source line
+	for _, r := range prefix {
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+
+	sb.WriteRune('^')
+
+	return pos, sb.String()
+}
@@ -0,0 +1,116 @@
+package corn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	input := "{\n\tfoo = 1\n\tbar }"
+	_, err := Evaluate(input)
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected SyntaxError, got %v", err)
+	}
+
+	if syntaxErr.Line != 3 || syntaxErr.Column != 6 || syntaxErr.Offset != 16 {
+		t.Fatalf("unexpected position %+v", syntaxErr.Position)
+	}
+
+	assertEqual(t, syntaxErr.Error(), "3:6: expected `=`, got Token(})")
+	assertEqual(t, syntaxErr.Snippet, "3 | \tbar }\n  | \t    ^")
+}
+
+func TestEvalErrorPosition(t *testing.T) {
+	input := "let {\n    $foo = 1\n} in {\n    bar = \"$foo\"\n    baz = $qux\n}"
+	_, err := Evaluate(input)
+
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected EvalError, got %v", err)
+	}
+
+	assertEqual(t, evalErr.Error(), "4:12: attempted to interpolate `$foo` which is not of type string")
+
+	_, err = Evaluate("{\n    foo = 1\n    foo.bar = $qux\n}")
+
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected EvalError, got %v", err)
+	}
+
+	assertEqual(t, evalErr.Error(), "3:15: input '$qux' does not exist")
+	assertEqual(t, evalErr.Snippet, "3 |     foo.bar = $qux\n  |               ^")
+}
+
+var malformedInputs = []string{
+	"",
+	"   ",
+	"{",
+	"}",
+	"{ a = ",
+	"{ a = 1",
+	"{ a = 1 } }",
+	"{ a = 1 } { b = 2 }",
+	"{ a = \"unterminated }",
+	"{ a = \"$5\" }",
+	"{ a = \"\\q\" }",
+	"{ a = \"\\u12\" }",
+	"{ a. = 1 }",
+	"{ a = 0x }",
+	"{ a = 1. }",
+	"{ a = [ 1 2 }",
+	"{ a = [ ..$b ] }",
+	"{ ..$b }",
+	"{ 'unterminated = 1 }",
+	"{ a = $ }",
+	"{ = }",
+	"{ a = = 1 }",
+	"let",
+	"let {",
+	"let { $a = }",
+	"let { $a = 1 } in",
+	"let { $a = 1 } { a = $a }",
+	"let { a = 1 } in { }",
+	"let { $a = 1 in { }",
+	"{ a = 1 } // trailing comment",
+	"// only a comment",
+	"{ a = 1 }\n\u00a0",
+	"{ a = \"\xff\" }",
+	"{ a = { b = 1 } a.b.c = 2 }",
+}
+
+func TestMalformedInputs(t *testing.T) {
+	for _, input := range malformedInputs {
+		_, err := Evaluate(input)
+		checkErrorType(t, input, err)
+	}
+}
+
+func FuzzEvaluate(f *testing.F) {
+	for _, input := range malformedInputs {
+		f.Add(input)
+	}
+
+	f.Add("let { $foo = 42 $bar = \"hello $foo\" } in { foo.bar = [ $foo ..$arr { a = null } ] 'quoted key' = 0x1f b = -1.5e3 }")
+	f.Add("{\n    // comment\n    multi = \"\n        line\n    \"\n}")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		_, err := Evaluate(input)
+		checkErrorType(t, input, err)
+	})
+}
+
+// Checks that any error returned from `Evaluate` is positioned.
+func checkErrorType(t *testing.T, input string, err error) {
+	if err == nil {
+		return
+	}
+
+	var syntaxErr *SyntaxError
+	var evalErr *EvalError
+
+	if !errors.As(err, &syntaxErr) && !errors.As(err, &evalErr) {
+		t.Errorf("expected SyntaxError or EvalError for %q, got %T: %v", input, err, err)
+	}
+}
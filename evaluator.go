@@ -3,6 +3,7 @@ package corn
 import (
 	"errors"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/iancoleman/orderedmap"
@@ -14,27 +15,83 @@ type Value interface {
 type Evaluation struct {
 	Inputs map[string]Rule[any]
 	Value  *orderedmap.OrderedMap
+
+	// The origin of each value in `Value`, keyed by its path in the form accepted by `Get`.
+	// Only set when `Options.Provenance` is enabled.
+	Provenance map[string]Origin
+
+	// inputs supplied through `Options.Inputs`, keyed by their `$` name
+	injected map[string]Value
+
+	// looks up environment variables for `$env_` inputs
+	lookupEnv func(name string) (string, bool)
+
+	// the environment variables which may be read
+	env *EnvPolicy
+
+	// usage against `Options.Limits`, and the current nesting depth
+	limits *limiter
+	depth  int
+
+	// values of the declared inputs resolved so far
+	resolver *resolver
+
+	// where to record the origin of the value being evaluated, if provenance is enabled
+	origin *originNode
+
+	// how to deep-merge objects, if enabled
+	merge *MergeOptions
+}
+
+// Resolves the inputs declared in the `let` block on first use,
+// following references between them depth-first.
+// Shared by every copy of the `Evaluation`.
+// The number of values within a value, including itself,
+// and the depth to which objects and arrays are nested within it.
+type valueSize struct {
+	nodes int
+	depth int
 }
 
+type resolver struct {
+	values map[string]Value
+
+	// the size of each resolved value, measured once
+	// so that each further reference can be counted towards the limits
+	sizes map[string]valueSize
+
+	// origins of the resolved values, if provenance is enabled
+	origins map[string]*originNode
+
+	// inputs currently being resolved, outermost first
+	stack []string
+}
+
+// Records the value rule of each declared input, to be resolved when first referenced,
+// so that inputs can reference others declared after them.
+// Where an input is declared more than once, the last declaration is used.
 func evalInputs(assign_block Rule[any], evaluation Evaluation) Evaluation {
 	for _, assignment := range assign_block.Rules {
 		var nameRule = assignment.Rules[0]
 		var name = (*nameRule.Data).(string)
 
-		var value = assignment.Rules[1]
-
-		if value.Id == ruleInput {
-			var refName = (*value.Data).(string)
-			evaluation.Inputs[name] = evaluation.Inputs[refName]
-		} else {
-			evaluation.Inputs[name] = value.Rules[0]
-		}
+		evaluation.Inputs[name] = assignment.Rules[1].Rules[0]
 	}
 
 	return evaluation
 }
 
 func evalValue(val Rule[any], evaluation Evaluation) (Value, error) {
+	err := evaluation.produce(val.Span, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if val.Id != ruleInput {
+		evaluation.record(Origin{Kind: OriginLiteral, Span: val.Span})
+	}
+
 	switch val.Id {
 	case ruleObject:
 		return evalObject(val, evaluation)
@@ -49,13 +106,12 @@ func evalValue(val Rule[any], evaluation Evaluation) (Value, error) {
 	case ruleString:
 		return evalString(val, evaluation)
 	case ruleInput:
-		var inputName = (*val.Data).(string)
-		return getInput(evaluation, inputName)
+		return getInput(evaluation, val)
 	case ruleNull:
 		return nil, nil
 
 	default:
-		return nil, errors.New("invalid value type: " + val.String())
+		return nil, evalError(val.Span, "invalid value type: "+val.String())
 	}
 }
 
@@ -71,8 +127,8 @@ func evalString(rule Rule[any], evaluation Evaluation) (string, error) {
 			sb.WriteRune((*rule.Data).(rune))
 			has_escape = true
 		case ruleInput:
-			var inputName = (*rule.Data).(string)
-			var val, err = getInput(evaluation, inputName)
+			// the string as a whole is the origin, not the interpolated input
+			var val, err = getInput(evaluation.recordInto(nil), rule)
 
 			if err != nil {
 				return "", err
@@ -82,9 +138,16 @@ func evalString(rule Rule[any], evaluation Evaluation) (string, error) {
 			case string:
 				sb.WriteString(val.(string))
 			default:
-				return "", errors.New("Attempted to interpolate `" + inputName + "` which is not of type string")
+				var inputName = (*rule.Data).(string)
+				return "", evalError(rule.Span, "attempted to interpolate `"+inputName+"` which is not of type string")
 			}
 		}
+
+		err := evaluation.checkString(rule.Span, sb.Len())
+
+		if err != nil {
+			return "", err
+		}
 	}
 
 	str := sb.String()
@@ -146,12 +209,18 @@ func trimMultilineString(str string) string {
 }
 
 func evalArray(arr Rule[any], evaluation Evaluation) ([]Value, error) {
+	evaluation, err := evaluation.enter(arr.Span)
+
+	if err != nil {
+		return nil, err
+	}
+
 	values := make([]Value, 0, 5)
 
 	for _, rule := range arr.Rules {
 		if rule.Id == ruleSpread {
-			var inputName = (*rule.Data).(string)
-			var value, err = getInput(evaluation, inputName)
+			spread := evaluation.newOrigin()
+			var value, err = getInput(evaluation.recordInto(spread), rule)
 
 			if err != nil {
 				return nil, err
@@ -159,13 +228,25 @@ func evalArray(arr Rule[any], evaluation Evaluation) ([]Value, error) {
 
 			switch value.(type) {
 			case []Value:
+				err = evaluation.produce(rule.Span, len(value.([]Value)))
+
+				if err != nil {
+					return nil, err
+				}
+
+				if evaluation.origin != nil {
+					for i := range value.([]Value) {
+						evaluation.origin.elements = append(evaluation.origin.elements, spread.element(i).clone(fillSpread((*rule.Data).(string))))
+					}
+				}
+
 				values = append(values, value.([]Value)...)
 			default:
-				return nil, errors.New("attempted to spread non-array input into array")
+				return nil, evalError(rule.Span, "attempted to spread non-array input into array")
 			}
 		} else {
 
-			value, err := evalValue(rule, evaluation)
+			value, err := evalValue(rule, evaluation.recordElement())
 
 			if err != nil {
 				return nil, err
@@ -180,43 +261,78 @@ func evalArray(arr Rule[any], evaluation Evaluation) ([]Value, error) {
 
 func evalObject(obj Rule[any], evaluation Evaluation) (*orderedmap.OrderedMap, error) {
 	if obj.Id != ruleObject {
-		return orderedmap.New(), errors.New("expected `object`, got " + obj.String())
+		return orderedmap.New(), evalError(obj.Span, "expected `object`, got "+obj.String())
+	}
+
+	evaluation, err := evaluation.enter(obj.Span)
+
+	if err != nil {
+		return orderedmap.New(), err
 	}
 
 	value_map := orderedmap.New()
 
 	for _, rule := range obj.Rules {
 		if rule.Id == ruleSpread {
-			var inputName = (*rule.Data).(string)
-			var value, err = getInput(evaluation, inputName)
+			spread := evaluation.newOrigin()
+			var value, err = getInput(evaluation.recordInto(spread), rule)
 
 			if err != nil {
 				return value_map, err
 			}
 
 			switch value.(type) {
-			case map[string]Value:
+			case *orderedmap.OrderedMap:
+				err = evaluation.produce(rule.Span, len(value.(*orderedmap.OrderedMap).Keys()))
+
+				if err != nil {
+					return value_map, err
+				}
+
 				for _, k := range value.(*orderedmap.OrderedMap).Keys() {
 					v, ok := value.(*orderedmap.OrderedMap).Get(k)
 
 					if !ok {
-						return value_map, errors.New("missing key when performing object spread")
+						return value_map, evalError(rule.Span, "missing key when performing object spread")
+					}
+
+					var origin *originNode
+
+					if evaluation.origin != nil {
+						origin = spread.child(k).clone(fillSpread((*rule.Data).(string)))
+					}
+
+					existing, exists := value_map.Get(k)
+					v = evaluation.mergeValue(existing, exists, v, evaluation.origin.child(k), origin)
+
+					if evaluation.origin != nil {
+						evaluation.origin.setChild(k, origin)
 					}
 
 					value_map.Set(k, v)
 				}
 			default:
-				return value_map, errors.New("attempted to spread non-object input into object")
+				return value_map, evalError(rule.Span, "attempted to spread non-object input into object")
 			}
 		} else {
 			var path = evalPath(rule.Rules[0])
-			var value, err = evalValue(rule.Rules[1], evaluation)
+			var existing, exists = getAtPath(value_map, path)
+			var previous = evaluation.origin.at(path)
+
+			var pairEvaluation = evaluation.recordPair(value_map, path, rule.Rules[0].Span)
+			var value, err = evalValue(rule.Rules[1], pairEvaluation)
 
 			if err != nil {
 				return value_map, err
 			}
 
-			addAtPath(value_map, path, value)
+			value = pairEvaluation.mergeValue(existing, exists, value, previous, pairEvaluation.origin)
+
+			err = addAtPath(value_map, path, value)
+
+			if err != nil {
+				return value_map, evalError(rule.Span, err.Error())
+			}
 		}
 	}
 
@@ -264,12 +380,41 @@ func addAtPath(obj *orderedmap.OrderedMap, path []string, value Value) error {
 	return nil
 }
 
-func getInput(evaluation Evaluation, name string) (Value, error) {
+// Returns the value of the input referenced by `ref`,
+// which is either an input or a spread rule.
+func getInput(evaluation Evaluation, ref Rule[any]) (Value, error) {
+	var name = (*ref.Data).(string)
+
+	if value, ok := evaluation.injected[name]; ok {
+		err := evaluation.checkStrings(ref.Span, value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		evaluation.record(Origin{Kind: OriginInjected, Span: ref.Span, Input: name})
+
+		// injected values are shared between references, so each gets its own copy
+		return copyValue(value), nil
+	}
+
 	if strings.HasPrefix(name, "$env_") {
 		envName := name[len("$env_"):]
-		value := os.Getenv(envName)
 
-		if value != "" {
+		if !evaluation.env.Allows(envName) {
+			return nil, evalError(ref.Span, "environment variable `"+envName+"` referenced by `"+name+"` is not allowed")
+		}
+
+		value, ok := evaluation.lookupEnv(envName)
+
+		if ok {
+			err := evaluation.checkString(ref.Span, len(value))
+
+			if err != nil {
+				return nil, err
+			}
+
+			evaluation.record(Origin{Kind: OriginEnv, Span: ref.Span, Input: name, Env: envName})
 			return value, nil
 		}
 	}
@@ -277,19 +422,205 @@ func getInput(evaluation Evaluation, name string) (Value, error) {
 	rule, ok := evaluation.Inputs[name]
 
 	if ok {
-		return evalValue(rule, evaluation)
+		evaluation, err := evaluation.enter(ref.Span)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return resolveInput(evaluation, name, rule, ref)
 	} else {
-		return nil, errors.New("input '" + name + "' does not exist")
+		return nil, evalError(ref.Span, "input '"+name+"' does not exist")
 	}
 }
 
-func evaluate(ast Rule[any]) (Evaluation, error) {
+// Returns the value of a declared input, evaluating it the first time it is referenced.
+// Each reference gets its own copy, as for injected inputs.
+func resolveInput(evaluation Evaluation, name string, rule Rule[any], ref Rule[any]) (Value, error) {
+	resolver := evaluation.resolver
+
+	if value, ok := resolver.values[name]; ok {
+		// the copy counts towards the limits as if the input were evaluated again
+		size := resolver.sizes[name]
+
+		if max := evaluation.limits.maxDepth(); evaluation.depth+size.depth > max {
+			return nil, limitError(ref.Span, depthMessage(max))
+		}
+
+		err := evaluation.produce(ref.Span, size.nodes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		evaluation.recordInput(name)
+		return copyValue(value), nil
+	}
+
+	for i, resolving := range resolver.stack {
+		if resolving == name {
+			cycle := append(append([]string{}, resolver.stack[i:]...), name)
+			return nil, evalError(ref.Span, "cycle detected: "+strings.Join(cycle, " -> "))
+		}
+	}
+
+	var origin *originNode
+
+	if resolver.origins != nil {
+		origin = &originNode{}
+	}
+
+	resolver.stack = append(resolver.stack, name)
+	value, err := evalValue(rule, evaluation.recordInto(origin))
+	resolver.stack = resolver.stack[:len(resolver.stack)-1]
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, depth := measureValue(value)
+	resolver.values[name] = value
+	resolver.sizes[name] = valueSize{nodes, depth}
+
+	if origin != nil {
+		resolver.origins[name] = origin
+	}
+
+	evaluation.recordInput(name)
+	return copyValue(value), nil
+}
+
+// Returns the number of values within a value, including itself,
+// and the depth to which objects and arrays are nested within it.
+func measureValue(value Value) (int, int) {
+	nodes, depth := 1, 0
+
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		for _, k := range value.Keys() {
+			v, _ := value.Get(k)
+			childNodes, childDepth := measureValue(v)
+			nodes += childNodes
+			depth = max(depth, childDepth)
+		}
+
+		depth++
+	case []Value:
+		for _, v := range value {
+			childNodes, childDepth := measureValue(v)
+			nodes += childNodes
+			depth = max(depth, childDepth)
+		}
+
+		depth++
+	}
+
+	return nodes, depth
+}
+
+// Returns a deep copy of a value,
+// so that chained keys cannot modify the original through a shared object.
+func copyValue(value Value) Value {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		obj := orderedmap.New()
+
+		for _, k := range value.Keys() {
+			v, _ := value.Get(k)
+			obj.Set(k, copyValue(v))
+		}
+
+		return obj
+	case []Value:
+		arr := make([]Value, len(value))
+
+		for i, v := range value {
+			arr[i] = copyValue(v)
+		}
+
+		return arr
+	default:
+		return value
+	}
+}
+
+// Converts the host-supplied inputs into Corn values,
+// adding the `$` prefix to their names where missing.
+func evalInjectedInputs(inputs map[string]any, evaluation Evaluation) (Evaluation, error) {
+	evaluation.injected = make(map[string]Value, len(inputs))
+
+	for name, input := range inputs {
+		if !strings.HasPrefix(name, "$") {
+			name = "$" + name
+		}
+
+		if !isInputName(name) {
+			return evaluation, &InputError{Name: name, Msg: "not a valid input name"}
+		}
+
+		// paths in conversion errors start from the input, such as `$input.key`
+		value, err := reflectValue(reflect.ValueOf(input), name)
+
+		if err != nil {
+			return evaluation, &InputError{Name: name, Msg: err.Error()}
+		}
+
+		evaluation.injected[name] = value
+	}
+
+	return evaluation, nil
+}
+
+// Checks whether the name is a valid input name, including the `$` prefix.
+func isInputName(name string) bool {
+	if len(name) < 2 || name[0] != '$' || !strings.ContainsRune(charsInputFirst, rune(name[1])) {
+		return false
+	}
+
+	for _, r := range name[2:] {
+		if !strings.ContainsRune(charsInput, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func evaluate(ast Rule[any], options Options) (Evaluation, error) {
 	var evaluation = Evaluation{
-		Inputs: make(map[string]Rule[any]),
+		Inputs:    make(map[string]Rule[any]),
+		lookupEnv: options.LookupEnv,
+		env:       options.Env,
+		merge:     options.Merge,
+		limits:    &limiter{Limits: options.Limits},
+		resolver:  &resolver{values: make(map[string]Value), sizes: make(map[string]valueSize)},
+	}
+
+	if options.Provenance {
+		evaluation.origin = &originNode{}
+		evaluation.resolver.origins = make(map[string]*originNode)
+	}
+
+	if evaluation.lookupEnv == nil {
+		evaluation.lookupEnv = os.LookupEnv
+	}
+
+	evaluation, err := evalInjectedInputs(options.Inputs, evaluation)
+
+	if err != nil {
+		return evaluation, err
+	}
+
+	if options.Strict {
+		err = checkInputs(ast, evaluation)
+
+		if err != nil {
+			return evaluation, err
+		}
 	}
 
 	if ast.Id != ruleConfig {
-		return evaluation, errors.New("expected `Config`, got " + ast.String())
+		return evaluation, evalError(ast.Span, "expected `Config`, got "+ast.String())
 	}
 
 	var firstRule = ast.Rules[0]
@@ -300,6 +631,7 @@ func evaluate(ast Rule[any]) (Evaluation, error) {
 		value, err := evalObject(ast.Rules[1], evaluation)
 
 		evaluation.Value = value
+		evaluation.Provenance = evaluation.provenance()
 
 		return evaluation, err
 
@@ -307,10 +639,11 @@ func evaluate(ast Rule[any]) (Evaluation, error) {
 		value, err := evalObject(ast.Rules[0], evaluation)
 
 		evaluation.Value = value
+		evaluation.Provenance = evaluation.provenance()
 
 		return evaluation, err
 
 	default:
-		return evaluation, errors.New("expected one of `assign_block` or `object`, got " + firstRule.String())
+		return evaluation, evalError(firstRule.Span, "expected one of `assign_block` or `object`, got "+firstRule.String())
 	}
 }
@@ -2,9 +2,11 @@ package corn
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/andreyvit/diff"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -187,3 +189,436 @@ func TestValueAfterTable(t *testing.T) {
 func TestVeryCompact(t *testing.T) {
 	testEqual(t, "very_compact")
 }
+
+func TestInjectedInputs(t *testing.T) {
+	input := `let {
+		$region = "eu-west-1"
+		$replicas = 1
+	} in {
+		region = $region
+		replicas = $replicas
+		url = "https://$host/$region"
+		..$defaults
+		tags = [ ..$tags ]
+	}`
+
+	evaluation, err := EvaluateWithOptions(input, Options{
+		Inputs: map[string]any{
+			"$region":  "us-east-1",
+			"host":     "example.com",
+			"defaults": map[string]any{"debug": false},
+			"tags":     []string{"a", "b"},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := json.Marshal(evaluation.Value)
+	assertEqual(t, string(output), `{"region":"us-east-1","replicas":1,"url":"https://example.com/us-east-1","debug":false,"tags":["a","b"]}`)
+
+	_, err = EvaluateWithOptions(input, Options{Inputs: map[string]any{"1bad": 1}})
+
+	var inputErr *InputError
+
+	if !errors.As(err, &inputErr) {
+		t.Fatalf("expected InputError for invalid input name, got %v", err)
+	}
+
+	assertEqual(t, err.Error(), "invalid input `$1bad`: not a valid input name")
+
+	_, err = EvaluateWithOptions(input, Options{Inputs: map[string]any{"defaults": map[string]any{"ch": make(chan int)}}})
+
+	if !errors.As(err, &inputErr) || inputErr.Name != "$defaults" {
+		t.Fatalf("expected InputError for invalid input value, got %v", err)
+	}
+
+	assertEqual(t, err.Error(), "invalid input `$defaults`: cannot marshal `$defaults.ch`: unsupported type chan int")
+}
+
+func TestLookupEnv(t *testing.T) {
+	input := `let {
+		$env_HOST = "localhost"
+		$env_PORT = "80"
+		$env_USER = "nobody"
+	} in { host = $env_HOST port = $env_PORT user = $env_USER }`
+
+	evaluation, err := EvaluateWithOptions(input, Options{
+		LookupEnv: MapEnv(map[string]string{
+			"HOST": "example.com",
+			"PORT": "",
+		}),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := json.Marshal(evaluation.Value)
+	assertEqual(t, string(output), `{"host":"example.com","port":"","user":"nobody"}`)
+}
+
+func TestEnvPolicy(t *testing.T) {
+	input := "let {\n    $env_APP_NAME = \"app\"\n    $env_SECRET = \"\"\n} in {\n    name = $env_APP_NAME\n    home = $env_HOME\n    secret = $env_SECRET\n}"
+	env := MapEnv(map[string]string{"APP_NAME": "corn", "HOME": "/home/corn", "SECRET": "hunter2"})
+
+	_, err := EvaluateWithOptions(input, Options{
+		LookupEnv: env,
+		Env:       &EnvPolicy{Allow: []string{"HOME"}, AllowPrefixes: []string{"APP_"}},
+	})
+
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected EvalError, got %v", err)
+	}
+
+	assertEqual(t, evalErr.Error(), "7:14: environment variable `SECRET` referenced by `$env_SECRET` is not allowed")
+
+	evaluation, err := EvaluateWithOptions(input, Options{
+		LookupEnv: env,
+		Env:       &EnvPolicy{},
+		Inputs:    map[string]any{"env_HOME": "/root", "env_SECRET": "injected"},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "`APP_NAME`") {
+		t.Fatalf("expected disallowed APP_NAME, got %v", err)
+	}
+
+	evaluation, err = EvaluateWithOptions(input, Options{
+		LookupEnv: env,
+		Env:       &EnvPolicy{AllowPrefixes: []string{"APP_", "HOME"}},
+		Inputs:    map[string]any{"env_SECRET": "injected"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := json.Marshal(evaluation.Value)
+	assertEqual(t, string(output), `{"name":"corn","home":"/home/corn","secret":"injected"}`)
+}
+
+func TestCompile(t *testing.T) {
+	program, err := Compile(`{ greeting = "hello $name" }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"foo", "bar"} {
+		evaluation, err := program.Evaluate(Options{Inputs: map[string]any{"name": name}})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		greeting, _ := evaluation.Value.Get("greeting")
+		assertEqual(t, greeting.(string), "hello "+name)
+	}
+
+	_, err = program.Evaluate(Options{})
+
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) || evalErr.Line != 1 {
+		t.Fatalf("expected positioned EvalError, got %v", err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	input := "let {\n    $host = \"localhost\"\n    $port = 80\n    $host = \"example.com\"\n    $env_HOME = \"/root\"\n    $loop = [ $loop ]\n} in {\n    url = \"http://$host\"\n    home = $env_HOME\n}"
+
+	_, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = EvaluateWithOptions(input, Options{
+		Strict:    true,
+		LookupEnv: MapEnv(map[string]string{"HOME": "/home/corn"}),
+	})
+
+	if err == nil {
+		t.Fatal("expected errors in strict mode")
+	}
+
+	var messages []string
+
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var evalErr *EvalError
+		if !errors.As(err, &evalErr) {
+			t.Fatalf("expected EvalError, got %v", err)
+		}
+
+		messages = append(messages, evalErr.Error())
+	}
+
+	assertEqual(t, strings.Join(messages, "\n"), strings.Join([]string{
+		"3:5: input `$port` is declared but never used",
+		"4:5: input `$host` is already declared",
+		"5:5: input `$env_HOME` is shadowed by environment variable `HOME`",
+		"6:5: input `$loop` is declared but never used",
+	}, "\n"))
+
+	_, err = EvaluateWithOptions(`let { $a = "x" $b = { ..$c } $c = { a = $a } } in { b = $b }`, Options{Strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inputs referenced only by unused inputs are also unused
+	_, err = EvaluateWithOptions(`let { $a = 1 $b = $a $c = [ $d ] $d = [ $c ] } in { x = 1 }`, Options{Strict: true})
+
+	assertEqual(t, fmt.Sprint(err), strings.Join([]string{
+		"1:7: input `$a` is declared but never used",
+		"1:14: input `$b` is declared but never used",
+		"1:22: input `$c` is declared but never used",
+		"1:34: input `$d` is declared but never used",
+	}, "\n"))
+
+	// as are inputs referenced only by a declaration which is replaced by an injected value
+	_, err = EvaluateWithOptions(`let { $a = 1 $b = $a } in { x = $b }`, Options{Strict: true, Inputs: map[string]any{"b": 2}})
+
+	assertEqual(t, fmt.Sprint(err), "1:7: input `$a` is declared but never used")
+}
+
+func TestInputResolution(t *testing.T) {
+	input := `let {
+		$url = "https://$host:$port"
+		$host = "example.com"
+		$port = "8080"
+		$defaults = { retries = 3 }
+	} in {
+		url = $url
+		primary = $defaults
+		primary.timeout = 10
+		secondary = $defaults
+	}`
+
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := json.Marshal(evaluation.Value)
+	assertEqual(t, string(output), `{"url":"https://example.com:8080","primary":{"retries":3,"timeout":10},"secondary":{"retries":3}}`)
+
+	tests := map[string]string{
+		`let { $a = $a } in { a = $a }`:                           "1:12: cycle detected: $a -> $a",
+		`let { $a = $b $b = [ ..$c ] $c = [ $a ] } in { a = $a }`: "1:36: cycle detected: $a -> $b -> $c -> $a",
+		`let { $a = "x$b" $b = { b = "$a" } } in { a = $a }`:      "1:30: cycle detected: $a -> $b -> $a",
+	}
+
+	for input, expected := range tests {
+		_, err := Evaluate(input)
+
+		var evalErr *EvalError
+		if !errors.As(err, &evalErr) {
+			t.Fatalf("expected EvalError for %s, got %v", input, err)
+		}
+
+		assertEqual(t, evalErr.Error(), expected)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	input := `let {
+    $port = 5432
+    $db = { host = "localhost" port = $port }
+    $env_DB_PASSWORD = "secret"
+    $tags = [ "a" ]
+} in {
+    db = $db
+    db.password = $env_DB_PASSWORD
+    ..$defaults
+    cache.'eu-west.1'.url = "redis://$env_DB_PASSWORD"
+    tags = [ ..$tags "b" ]
+}`
+
+	evaluation, err := EvaluateWithOptions(input, Options{
+		Provenance: true,
+		LookupEnv:  MapEnv(map[string]string{"DB_PASSWORD": "hunter2"}),
+		Inputs:     map[string]any{"defaults": map[string]any{"debug": true}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+
+	for _, path := range []string{"db", "db.host", "db.port", "db.password", "debug", "cache", "cache.'eu-west.1'", "cache.'eu-west.1'.url", "tags", "tags.0", "tags.1"} {
+		origin, ok := evaluation.Provenance[path]
+
+		if !ok {
+			t.Fatalf("missing provenance for %s", path)
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: %s %s input=%s env=%s spread=%s", path, origin.Kind, origin.Position, origin.Input, origin.Env, origin.Spread))
+	}
+
+	assertEqual(t, strings.Join(lines, "\n"), strings.Join([]string{
+		"db: literal 3:11 input=$db env= spread=",
+		"db.host: literal 3:20 input=$db env= spread=",
+		"db.port: literal 2:13 input=$port env= spread=",
+		"db.password: env 8:19 input=$env_DB_PASSWORD env=DB_PASSWORD spread=",
+		"debug: injected 9:5 input=$defaults env= spread=$defaults",
+		"cache: literal 10:5 input= env= spread=",
+		"cache.'eu-west.1': literal 10:5 input= env= spread=",
+		"cache.'eu-west.1'.url: literal 10:29 input= env= spread=",
+		"tags: literal 11:12 input= env= spread=",
+		"tags.0: literal 5:15 input=$tags env= spread=$tags",
+		"tags.1: literal 11:22 input= env= spread=",
+	}, "\n"))
+
+	if len(evaluation.Provenance) != 11 {
+		t.Fatalf("expected 11 paths, got %d", len(evaluation.Provenance))
+	}
+
+	evaluation, _ = Evaluate(input)
+
+	if evaluation.Provenance != nil {
+		t.Fatal("expected no provenance unless enabled")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input  string
+		limits Limits
+		err    string
+	}{
+		{`{ foo = "bar" }`, Limits{MaxBytes: 10}, "1:11: input exceeds the limit of 10 bytes"},
+		{`{ foo = [ 1 2 3 ] }`, Limits{MaxTokens: 6}, "1:15: input exceeds the limit of 6 tokens"},
+		{`{ foo = [ [ [ 1 ] ] ] }`, Limits{MaxDepth: 3}, "1:13: nesting exceeds the limit of 3 levels"},
+		{`let { $a = [ 1 ] $b = [ $a ] } in { b = [ $b ] }`, Limits{MaxDepth: 4}, "1:25: nesting exceeds the limit of 4 levels"},
+		{`let { $a = [ 1 ] } in { a = $a b = [ [ $a ] ] }`, Limits{MaxDepth: 4}, "1:40: nesting exceeds the limit of 4 levels"},
+		{`let { $a = [ 1 2 3 4 ] $b = [ $a $a $a $a ] } in { c = [ $b $b $b ] }`, Limits{MaxNodes: 50}, "1:64: result exceeds the limit of 50 values"},
+		{`let { $a = [ 1 2 3 4 ] $b = [ ..$a ..$a ] } in { b = [ ..$b ..$b ] }`, Limits{MaxNodes: 20}, "1:56: result exceeds the limit of 20 values"},
+		{`let { $a = "abcd" $b = "$a$a" } in { b = "$b$b" }`, Limits{MaxStringLength: 12}, "1:45: string exceeds the limit of 12 bytes"},
+	}
+
+	for _, test := range tests {
+		_, err := EvaluateWithOptions(test.input, Options{Limits: test.limits})
+
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expected limit error for %s, got %v", test.input, err)
+		}
+
+		assertEqual(t, err.Error(), test.err)
+	}
+
+	// strings from outside the source are checked where they are referenced
+	external := []Options{
+		{LookupEnv: MapEnv(map[string]string{"HOST": "example.com"})},
+		{Inputs: map[string]any{"env_HOST": map[string]any{"hosts": []string{"a", "example.com"}}}},
+	}
+
+	for _, options := range external {
+		options.Limits = Limits{MaxStringLength: 8}
+		_, err := EvaluateWithOptions(`let { $env_HOST = "a" } in { host = $env_HOST }`, options)
+
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expected limit error for external string, got %v", err)
+		}
+
+		assertEqual(t, err.Error(), "1:37: string exceeds the limit of 8 bytes")
+	}
+
+	_, err := EvaluateWithOptions(strings.Repeat("{ a = ", 100000), Options{Limits: Limits{MaxDepth: 64}})
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error for deep nesting, got %v", err)
+	}
+
+	// nesting is limited even without limits, so that it cannot exhaust the stack
+	nested := func(depth int) string {
+		return "{ a = " + strings.Repeat("[", depth-1) + strings.Repeat("]", depth-1) + " }"
+	}
+
+	_, err = Evaluate(nested(MaxNesting))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Evaluate(nested(3 * MaxNesting))
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error for deep nesting without limits, got %v", err)
+	}
+
+	assertEqual(t, err.Error(), "1:10006: nesting exceeds the limit of 10000 levels")
+
+	chain := new(strings.Builder)
+	chain.WriteString("let { $a0 = 1 ")
+
+	for i := 1; i <= MaxNesting; i++ {
+		chain.WriteString("$a" + strconv.Itoa(i) + " = [ $a" + strconv.Itoa(i-1) + " ] ")
+	}
+
+	chain.WriteString("} in { a = $a" + strconv.Itoa(MaxNesting) + " }")
+
+	_, err = Evaluate(chain.String())
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error for deeply nested inputs without limits, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	evaluation, err := Evaluate(`{ server.'eu.1'.hosts = [ "a" "b" ] }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := evaluation.Get("server.'eu.1'.hosts.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, value.(string), "b")
+
+	for _, path := range []string{"server.eu.1", "server.'eu.1'.hosts.2", "server..x", "server.'eu.1"} {
+		if _, err := evaluation.Get(path); err == nil {
+			t.Errorf("expected error for path %s", path)
+		}
+	}
+}
+
+func TestTypedGetters(t *testing.T) {
+	evaluation, err := Evaluate(`{ db = { host = "localhost" port = 5432 ratio = 0.5 tls = true hosts = [ "a" ] } }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := evaluation.GetString("db.host")
+	assertEqual(t, host, "localhost")
+
+	port, err := evaluation.GetInt("db.port")
+
+	if err != nil || port != 5432 {
+		t.Fatalf("unexpected port %v: %v", port, err)
+	}
+
+	ratio, _ := evaluation.GetFloat("db.ratio")
+	portFloat, _ := evaluation.GetFloat("db.port")
+	tls, _ := evaluation.GetBool("db.tls")
+	hosts, _ := evaluation.GetSlice("db.hosts")
+	db, _ := evaluation.GetObject("db")
+
+	if ratio != 0.5 || portFloat != 5432 || !tls || len(hosts) != 1 || db.Keys()[0] != "host" {
+		t.Fatalf("unexpected values %v %v %v %v %v", ratio, portFloat, tls, hosts, db)
+	}
+
+	_, err = evaluation.GetString("db.port")
+	assertEqual(t, err.Error(), "value at `db.port` is integer, expected string")
+
+	_, err = evaluation.GetInt("db.missing")
+	assertEqual(t, err.Error(), "key `db.missing` does not exist")
+}
@@ -0,0 +1,642 @@
+package corn
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// A repeated value hoisted into the `let` block.
+type extractedInput struct {
+	name  string
+	value Value
+	// id of the value in the `valueTable`
+	id int
+}
+
+type valueKind uint8
+
+const (
+	valueScalar valueKind = iota
+	valueString
+	valueObject
+	valueArray
+)
+
+// Identifies a value by its contents.
+// Objects and arrays are identified one key or element at a time,
+// from the id of the value without its last key or element,
+// so that each leading run of keys of an object has an id of its own.
+type valueKey struct {
+	kind valueKind
+	// id of the object or array without its last key or element
+	prev int
+	// the last key of an object, or the encoding of a scalar or string
+	text string
+	// id of the last value of an object or array
+	value int
+}
+
+type valueInfo struct {
+	// length when written compactly, or -1 if it cannot be written
+	length int
+	// number of keys or elements
+	size int
+	// for objects, an object whose first `size` keys make up the value
+	obj *orderedmap.OrderedMap
+	// for arrays, the ids of the elements
+	elements []int
+	// for other values, the value itself
+	value Value
+}
+
+// Reports whether the value is a string, or a non-empty object or array, like `isExtractable`.
+func (info valueInfo) extractable() bool {
+	if info.obj != nil || info.elements != nil {
+		return info.size > 0
+	}
+
+	_, ok := info.value.(string)
+	return ok
+}
+
+// The ids of the leading keys and of the values of an object.
+type objectIds struct {
+	// chain[n] is the id of the first n keys
+	chain  []int
+	values []int
+}
+
+// Identifies an array by its first element and length.
+type arrayRef struct {
+	first  *Value
+	length int
+}
+
+// Assigns an id to each distinct value within a tree,
+// so that values can be compared and counted without writing them out.
+// Each value is only visited once when building the table.
+type valueTable struct {
+	ids     map[valueKey]int
+	infos   []valueInfo
+	objects map[*orderedmap.OrderedMap]objectIds
+	arrays  map[arrayRef]int
+}
+
+func newValueTable() *valueTable {
+	return &valueTable{
+		ids:     make(map[valueKey]int),
+		objects: make(map[*orderedmap.OrderedMap]objectIds),
+		arrays:  make(map[arrayRef]int),
+	}
+}
+
+func (table *valueTable) id(key valueKey, info valueInfo) int {
+	if id, ok := table.ids[key]; ok {
+		return id
+	}
+
+	id := len(table.infos)
+	table.ids[key] = id
+	table.infos = append(table.infos, info)
+
+	return id
+}
+
+// Returns the id of the value, adding it and every value within it to the table.
+// Values within a value always have lower ids than the value itself.
+func (table *valueTable) add(value Value) int {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		if ids, ok := table.objects[value]; ok {
+			return ids.chain[len(ids.chain)-1]
+		}
+
+		keys := value.Keys()
+		ids := objectIds{chain: make([]int, 1, len(keys)+1), values: make([]int, 0, len(keys))}
+		ids.chain[0] = table.id(valueKey{kind: valueObject}, valueInfo{length: 2, obj: value})
+
+		for i, key := range keys {
+			child, _ := value.Get(key)
+			childId := table.add(child)
+			prev := table.infos[ids.chain[i]].length
+			segment, err := quotePathSegment(key)
+			length := -1
+
+			// `{ key = value }`, with each further key adding ` key = value`
+			if err == nil && prev >= 0 && table.infos[childId].length >= 0 {
+				length = prev + 4 + len(segment) + table.infos[childId].length
+
+				if i == 0 {
+					length++
+				}
+			}
+
+			ids.chain = append(ids.chain, table.id(valueKey{kind: valueObject, prev: ids.chain[i], text: key, value: childId}, valueInfo{length: length, size: i + 1, obj: value}))
+			ids.values = append(ids.values, childId)
+		}
+
+		table.objects[value] = ids
+		return ids.chain[len(keys)]
+	case []Value:
+		elements := make([]int, len(value))
+		id := table.id(valueKey{kind: valueArray}, valueInfo{length: 2, value: value[:0]})
+
+		for i, element := range value {
+			elements[i] = table.add(element)
+			prev := table.infos[id].length
+			length := -1
+
+			// `[ value ]`, with each further element adding ` value`
+			if prev >= 0 && table.infos[elements[i]].length >= 0 {
+				length = prev + 1 + table.infos[elements[i]].length
+
+				if i == 0 {
+					length++
+				}
+			}
+
+			id = table.id(valueKey{kind: valueArray, prev: id, value: elements[i]}, valueInfo{length: length, size: i + 1, elements: elements[:i+1], value: value[:i+1]})
+		}
+
+		if len(value) > 0 {
+			table.arrays[arrayRef{first: &value[0], length: len(value)}] = id
+		}
+
+		return id
+	case string:
+		return table.id(valueKey{kind: valueString, text: value}, valueInfo{length: len(quoteString(value)), value: value})
+	default:
+		enc := &encoder{compact: true}
+
+		if enc.writeValue(value) != nil {
+			return table.id(valueKey{kind: valueScalar}, valueInfo{length: -1})
+		}
+
+		text := enc.sb.String()
+		return table.id(valueKey{kind: valueScalar, text: text}, valueInfo{length: len(text), value: value})
+	}
+}
+
+// Returns the id of a value already in the table.
+func (table *valueTable) lookup(value Value) (int, bool) {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		ids, ok := table.objects[value]
+
+		if !ok {
+			return 0, false
+		}
+
+		return ids.chain[len(ids.chain)-1], true
+	case []Value:
+		if len(value) == 0 {
+			return 0, false
+		}
+
+		id, ok := table.arrays[arrayRef{first: &value[0], length: len(value)}]
+		return id, ok
+	case string:
+		id, ok := table.ids[valueKey{kind: valueString, text: value}]
+		return id, ok
+	default:
+		return 0, false
+	}
+}
+
+// Returns a value with the given id.
+// Leading runs of keys are copied into a new object, which is added to the table.
+func (table *valueTable) value(id int) Value {
+	info := table.infos[id]
+
+	if info.obj == nil {
+		return info.value
+	}
+
+	ids := table.objects[info.obj]
+
+	if info.size == len(ids.values) {
+		return info.obj
+	}
+
+	prefix := prefixObject(info.obj, info.size)
+	table.objects[prefix] = objectIds{chain: ids.chain[:info.size+1], values: ids.values[:info.size]}
+
+	return prefix
+}
+
+// Chooses repeated values within an object to assign to inputs.
+//
+// The object is walked in the same way as the encoder writes it,
+// counting each occurrence of every value and of every leading run of at least two keys of an object.
+// Values are then chosen one at a time, taking whichever saves the most text.
+// Occurrences within a chosen value are no longer written, except once in its declaration,
+// and objects starting with a chosen run of keys no longer write those keys,
+// so after each choice the counts of only the affected values are updated.
+type extractor struct {
+	table          *valueTable
+	minOccurrences int
+	minLength      int
+
+	// times each value is reached by the walk, other than at the top of the object or a declaration
+	visits []int
+	// times each run of keys is counted as the leading keys of an object
+	prefixes []int
+	// times the values within each value are walked
+	expanded []int
+
+	chosen []bool
+
+	// for each object, the number of leading keys replaced by a spread
+	spread []int
+	// objects which start with each run of keys, excluding the run itself
+	users   map[int][]int
+	indexed []bool
+
+	// order in which each candidate was first reached, or -1, and the key it was reached under
+	rank  []int
+	hints []string
+	ranks int
+
+	candidates candidateHeap
+	dirty      []int
+	isDirty    []bool
+}
+
+// Returns the chosen inputs, in an order where each is declared before any input which references it,
+// and the table identifying the values of the object.
+func extractInputs(root *orderedmap.OrderedMap, minOccurrences int, minLength int) ([]extractedInput, *valueTable) {
+	table := newValueTable()
+	rootId := table.add(root)
+	n := len(table.infos)
+
+	ex := &extractor{
+		table:          table,
+		minOccurrences: minOccurrences,
+		minLength:      minLength,
+		visits:         make([]int, n),
+		prefixes:       make([]int, n),
+		expanded:       make([]int, n),
+		chosen:         make([]bool, n),
+		spread:         make([]int, n),
+		users:          make(map[int][]int),
+		indexed:        make([]bool, n),
+		rank:           make([]int, n),
+		hints:          make([]string, n),
+		isDirty:        make([]bool, n),
+	}
+
+	for i := range ex.rank {
+		ex.rank[i] = -1
+	}
+
+	for _, ids := range table.objects {
+		ex.index(ids.chain[len(ids.chain)-1])
+	}
+
+	ex.expand(rootId, 1, "value")
+
+	var inputs []extractedInput
+	names := map[string]bool{}
+
+	for {
+		ex.flush()
+		best, ok := ex.best()
+
+		if !ok {
+			break
+		}
+
+		ex.choose(best)
+		name := inputName(ex.hints[best], names)
+		inputs = append(inputs, extractedInput{name: name, value: table.value(best), id: best})
+	}
+
+	// values can only contain values shorter than themselves
+	sort.SliceStable(inputs, func(i, j int) bool {
+		return table.infos[inputs[i].id].length < table.infos[inputs[j].id].length
+	})
+
+	return inputs, table
+}
+
+// Records an object as starting with each of its leading runs of keys.
+func (ex *extractor) index(id int) {
+	if ex.indexed[id] {
+		return
+	}
+
+	ex.indexed[id] = true
+	info := ex.table.infos[id]
+	chain := ex.table.objects[info.obj].chain
+
+	for n := 2; n < info.size; n++ {
+		ex.users[chain[n]] = append(ex.users[chain[n]], id)
+
+		if ex.chosen[chain[n]] {
+			ex.spread[id] = n
+		}
+	}
+}
+
+// Walks the values within a value `delta` more times.
+func (ex *extractor) expand(id int, delta int, hint string) {
+	ex.expanded[id] += delta
+	info := ex.table.infos[id]
+
+	if info.obj == nil {
+		for _, element := range info.elements {
+			ex.visit(element, delta, hint, "")
+		}
+
+		return
+	}
+
+	ids := ex.table.objects[info.obj]
+	spread := ex.spread[id]
+
+	if spread == 0 {
+		for n := 2; n < info.size; n++ {
+			ex.prefixes[ids.chain[n]] += delta
+			ex.touch(ids.chain[n], hint, "_base")
+		}
+	}
+
+	keys := info.obj.Keys()
+
+	for i := spread; i < info.size; i++ {
+		ex.visit(ids.values[i], delta, keys[i], "")
+	}
+}
+
+// Reaches a value `delta` more times, walking the values within it unless it has been chosen.
+func (ex *extractor) visit(id int, delta int, hint string, suffix string) {
+	ex.visits[id] += delta
+	ex.touch(id, hint, suffix)
+
+	if !ex.chosen[id] {
+		ex.expand(id, delta, hint)
+	}
+}
+
+// Notes that the count of a value has changed,
+// recording where it was first reached if it is a candidate.
+func (ex *extractor) touch(id int, hint string, suffix string) {
+	info := ex.table.infos[id]
+
+	if info.length < ex.minLength || !info.extractable() {
+		return
+	}
+
+	if ex.rank[id] < 0 {
+		ex.rank[id] = ex.ranks
+		ex.hints[id] = hint + suffix
+		ex.ranks++
+	}
+
+	if !ex.isDirty[id] {
+		ex.isDirty[id] = true
+		ex.dirty = append(ex.dirty, id)
+	}
+}
+
+func (ex *extractor) count(id int) int {
+	return ex.visits[id] + ex.prefixes[id]
+}
+
+func (ex *extractor) savings(id int) int {
+	return (ex.count(id) - 1) * ex.table.infos[id].length
+}
+
+// Queues the candidates whose counts have changed.
+func (ex *extractor) flush() {
+	for _, id := range ex.dirty {
+		ex.isDirty[id] = false
+
+		if !ex.chosen[id] && ex.count(id) >= ex.minOccurrences {
+			heap.Push(&ex.candidates, candidate{id: id, savings: ex.savings(id), rank: ex.rank[id]})
+		}
+	}
+
+	ex.dirty = ex.dirty[:0]
+}
+
+// Returns the candidate which saves the most text,
+// or the first reached of those which save the same.
+func (ex *extractor) best() (int, bool) {
+	for ex.candidates.Len() > 0 {
+		top := ex.candidates[0]
+
+		// entries are left in place when counts change
+		if ex.chosen[top.id] || ex.count(top.id) < ex.minOccurrences || ex.savings(top.id) != top.savings {
+			heap.Pop(&ex.candidates)
+			continue
+		}
+
+		return top.id, true
+	}
+
+	return 0, false
+}
+
+// Assigns a value to an input, updating the counts of the values it affects.
+func (ex *extractor) choose(id int) {
+	ex.chosen[id] = true
+	info := ex.table.infos[id]
+
+	if info.obj != nil {
+		ex.index(id)
+	}
+
+	// occurrences are replaced by references, and the value is walked once in its declaration
+	ex.expand(id, 1-ex.expanded[id], "")
+
+	if info.obj == nil {
+		return
+	}
+
+	for _, user := range ex.users[id] {
+		if info.size > ex.spread[user] {
+			ex.respread(user, info.size)
+		}
+	}
+}
+
+// Replaces the first `n` keys of an object with a spread.
+func (ex *extractor) respread(id int, n int) {
+	previous := ex.spread[id]
+	ex.spread[id] = n
+	walks := ex.expanded[id]
+
+	if walks == 0 {
+		return
+	}
+
+	info := ex.table.infos[id]
+	ids := ex.table.objects[info.obj]
+
+	if previous == 0 {
+		for m := 2; m < info.size; m++ {
+			ex.prefixes[ids.chain[m]] -= walks
+			ex.touch(ids.chain[m], "", "")
+		}
+	}
+
+	for i := previous; i < n; i++ {
+		ex.visit(ids.values[i], -walks, "", "")
+	}
+}
+
+type candidate struct {
+	id      int
+	savings int
+	rank    int
+}
+
+// Orders candidates by the text they save, then by when they were first reached.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int { return len(h) }
+
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].savings != h[j].savings {
+		return h[i].savings > h[j].savings
+	}
+
+	return h[i].rank < h[j].rank
+}
+
+func (h candidateHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *candidateHeap) Push(x any) { *h = append(*h, x.(candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func isExtractable(value Value) bool {
+	switch value := value.(type) {
+	case string:
+		return true
+	case *orderedmap.OrderedMap:
+		return len(value.Keys()) > 0
+	case []Value:
+		return len(value) > 0
+	default:
+		return false
+	}
+}
+
+// Returns an object containing the first `n` keys of `obj`.
+func prefixObject(obj *orderedmap.OrderedMap, n int) *orderedmap.OrderedMap {
+	prefix := orderedmap.New()
+
+	for _, key := range obj.Keys()[:n] {
+		value, _ := obj.Get(key)
+		prefix.Set(key, value)
+	}
+
+	return prefix
+}
+
+// Returns a valid input name based on the hint which is not already in use.
+func inputName(hint string, names map[string]bool) string {
+	sb := new(strings.Builder)
+
+	for _, r := range hint {
+		if strings.ContainsRune(charsInput, r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+
+	base := strings.Trim(sb.String(), "_")
+
+	// `$env_` inputs would be read from the environment instead
+	if base == "" || !strings.ContainsRune(charsInputFirst, rune(base[0])) || strings.HasPrefix(base, "env_") {
+		base = "value_" + base
+	}
+
+	name := "$" + base
+
+	for i := 2; names[name]; i++ {
+		name = "$" + base + "_" + strconv.Itoa(i)
+	}
+
+	names[name] = true
+	return name
+}
+
+// Returns the input to write in place of the value, if any.
+func (enc *encoder) inputFor(value Value) (string, bool) {
+	if len(enc.inputs) == 0 || !isExtractable(value) {
+		return "", false
+	}
+
+	id, ok := enc.values.lookup(value)
+
+	if !ok {
+		return "", false
+	}
+
+	name, ok := enc.inputs[id]
+	return name, ok
+}
+
+// Returns the input to spread at the start of the object, and the number of keys it replaces.
+// This is the input holding the longest run of leading keys, excluding the whole object.
+func (enc *encoder) spreadFor(obj *orderedmap.OrderedMap) (string, int) {
+	if len(enc.inputs) == 0 {
+		return "", 0
+	}
+
+	ids, ok := enc.values.objects[obj]
+
+	if !ok {
+		return "", 0
+	}
+
+	for n := len(ids.values) - 1; n >= 2; n-- {
+		if name, ok := enc.inputs[ids.chain[n]]; ok {
+			return name, n
+		}
+	}
+
+	return "", 0
+}
+
+// Writes the `let` block declaring the inputs,
+// after which their values are replaced by references.
+func (enc *encoder) writeLet(inputs []extractedInput, values *valueTable) error {
+	enc.inputs = map[int]string{}
+	enc.values = values
+
+	enc.sb.WriteString("let {")
+	enc.depth++
+
+	for _, input := range inputs {
+		enc.newline()
+		enc.sb.WriteString(input.name + " = ")
+
+		err := enc.writeValue(input.value)
+
+		if err != nil {
+			return err
+		}
+
+		enc.inputs[input.id] = input.name
+	}
+
+	enc.depth--
+	enc.newline()
+	enc.sb.WriteString("} in ")
+
+	return nil
+}
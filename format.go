@@ -0,0 +1,339 @@
+package corn
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	formatIndent    = "    "
+	formatLineWidth = 80
+)
+
+// Format returns the input Corn source in canonical form.
+//
+// Each key, assignment and spread is placed on its own line,
+// indented by four spaces per level of nesting,
+// with single spaces around `=` and the `let { } in` block
+// laid out in the same way as objects.
+// Arrays of simple values are kept on a single line where they fit,
+// otherwise each element is placed on its own line.
+//
+// Comments are preserved, either at the end of the line they were on
+// or on their own line, as are single blank lines between entries.
+// Keys, strings and numbers are written exactly as they appear in the source.
+//
+// Formatting is idempotent, so formatting the output again returns it unchanged.
+// A `*SyntaxError` is returned if the input is not valid Corn.
+func Format(input string) (string, error) {
+	_, err := compile(input)
+
+	if err != nil {
+		return "", locateError(err, input)
+	}
+
+	tokens, err := scan(input, scanComments)
+
+	if err != nil {
+		return "", locateError(err, input)
+	}
+
+	f := &formatter{source: input, tokens: tokens, blockStart: true}
+	f.formatConfig()
+
+	return f.sb.String() + "\n", nil
+}
+
+// Writes a token stream with comments in canonical form.
+//
+// The input has already been validated by the parser,
+// so the formatter assumes the tokens are well-formed.
+type formatter struct {
+	source string
+	tokens []Token[any]
+	pos    int
+	sb     strings.Builder
+	depth  int
+
+	// end of the last token consumed, including comments
+	prevEnd int
+	// whether nothing has yet been written in the current block
+	blockStart bool
+	// comments found within an entry, written once the entry is complete
+	pending []Token[any]
+}
+
+func (f *formatter) text(token Token[any]) string {
+	return f.source[token.Span.Start:token.Span.End]
+}
+
+// Returns the next token which is not a comment.
+func (f *formatter) peek() Token[any] {
+	for i := f.pos; i < len(f.tokens); i++ {
+		if f.tokens[i].Id != tokenComment {
+			return f.tokens[i]
+		}
+	}
+
+	return f.tokens[len(f.tokens)-1]
+}
+
+// Consumes the next token which is not a comment,
+// setting aside any comments before it until the end of the current entry.
+func (f *formatter) next() Token[any] {
+	for f.tokens[f.pos].Id == tokenComment {
+		f.pending = append(f.pending, f.tokens[f.pos])
+		f.pos++
+	}
+
+	token := f.tokens[f.pos]
+
+	if token.Id != tokenEOF {
+		f.pos++
+	}
+
+	f.prevEnd = token.Span.End
+	return token
+}
+
+// Starts a new line at the current indent,
+// preserving a single blank line if there was one in the source before `start`.
+func (f *formatter) newline(start int) {
+	if !f.blockStart && strings.Count(f.source[f.prevEnd:start], "\n") > 1 {
+		f.sb.WriteRune('\n')
+	}
+
+	f.lineBreak()
+	f.blockStart = false
+}
+
+func (f *formatter) lineBreak() {
+	if f.sb.Len() > 0 {
+		f.sb.WriteRune('\n')
+	}
+
+	f.sb.WriteString(strings.Repeat(formatIndent, f.depth))
+}
+
+// Writes any comments set aside during the last entry,
+// followed by those up to the next token.
+//
+// Comments which were on the same line as the previous token stay at the end of the line,
+// while the others are placed on their own line.
+func (f *formatter) comments() {
+	for i, comment := range f.pending {
+		if i == 0 {
+			f.sb.WriteString(" " + f.commentText(comment))
+		} else {
+			f.lineBreak()
+			f.sb.WriteString(f.commentText(comment))
+		}
+	}
+
+	f.pending = nil
+
+	for f.tokens[f.pos].Id == tokenComment {
+		comment := f.tokens[f.pos]
+		f.pos++
+
+		if f.sb.Len() > 0 && !strings.Contains(f.source[f.prevEnd:comment.Span.Start], "\n") {
+			f.sb.WriteString(" " + f.commentText(comment))
+		} else {
+			f.newline(comment.Span.Start)
+			f.sb.WriteString(f.commentText(comment))
+		}
+
+		f.prevEnd = comment.Span.End
+	}
+}
+
+func (f *formatter) commentText(comment Token[any]) string {
+	return strings.TrimRight(f.text(comment), charsWhitespace)
+}
+
+func (f *formatter) formatConfig() {
+	f.comments()
+
+	if f.peek().Id == tokenLet {
+		f.startLine()
+		f.next()
+		f.sb.WriteString("let ")
+		f.formatBlock(tokenBraceClose, f.formatAssignment)
+
+		f.next() // in
+		f.sb.WriteString(" in ")
+		f.formatBlock(tokenBraceClose, f.formatEntry)
+	} else {
+		f.startLine()
+		f.formatBlock(tokenBraceClose, f.formatEntry)
+	}
+
+	f.comments()
+}
+
+// Starts the first line of the output, after any leading comments.
+func (f *formatter) startLine() {
+	if f.sb.Len() > 0 {
+		f.newline(f.peek().Span.Start)
+	}
+}
+
+// Writes a block opened by the next token and closed by `closeId`,
+// placing each entry on its own line.
+// Blocks with no entries or comments are written as `{}` or `[]`.
+func (f *formatter) formatBlock(closeId tokenId, entry func()) {
+	open := f.next()
+	f.sb.WriteString(f.text(open))
+
+	f.depth++
+	f.blockStart = true
+	openLen := f.sb.Len()
+
+	for {
+		f.comments()
+
+		if f.peek().Id == closeId || f.peek().Id == tokenEOF {
+			break
+		}
+
+		f.newline(f.peek().Span.Start)
+		entry()
+	}
+
+	f.depth--
+	f.blockStart = false
+
+	if f.sb.Len() > openLen {
+		f.lineBreak()
+	}
+
+	f.sb.WriteString(f.text(f.next()))
+}
+
+func (f *formatter) formatAssignment() {
+	input := f.next()
+	f.next() // =
+
+	f.sb.WriteString(f.text(input) + " = ")
+	f.formatValue()
+}
+
+func (f *formatter) formatEntry() {
+	if f.peek().Id == tokenSpread {
+		f.next()
+		f.sb.WriteString(".." + f.text(f.next()))
+		return
+	}
+
+	for {
+		token := f.next()
+
+		if token.Id == tokenPathSegment {
+			f.sb.WriteString(f.text(token))
+		}
+
+		if f.peek().Id == tokenEquals {
+			break
+		}
+
+		if token.Id == tokenPathSegment {
+			f.sb.WriteRune('.')
+		}
+	}
+
+	f.next() // =
+	f.sb.WriteString(" = ")
+	f.formatValue()
+}
+
+func (f *formatter) formatArrayElement() {
+	if f.peek().Id == tokenSpread {
+		f.next()
+		f.sb.WriteString(".." + f.text(f.next()))
+		return
+	}
+
+	f.formatValue()
+}
+
+func (f *formatter) formatValue() {
+	switch f.peek().Id {
+	case tokenBraceOpen:
+		f.formatBlock(tokenBraceClose, f.formatEntry)
+	case tokenBracketOpen:
+		if inline, ok := f.inlineArray(); ok {
+			f.sb.WriteString(inline)
+			return
+		}
+
+		f.formatBlock(tokenBracketClose, f.formatArrayElement)
+	case tokenDoubleQuote:
+		f.sb.WriteString(f.stringText())
+	default:
+		f.sb.WriteString(f.text(f.next()))
+	}
+}
+
+// Consumes a string, returning its source text unchanged.
+func (f *formatter) stringText() string {
+	open := f.next()
+	token := f.next()
+
+	for token.Id != tokenDoubleQuote && token.Id != tokenEOF {
+		token = f.next()
+	}
+
+	return f.source[open.Span.Start:token.Span.End]
+}
+
+// Returns the array starting at the next token written on a single line,
+// and consumes it, if it only contains simple values and fits within the line width.
+func (f *formatter) inlineArray() (string, bool) {
+	start := f.pos
+	prevEnd := f.prevEnd
+	pending := f.pending
+
+	var parts []string
+	f.next() // [
+	inner := len(f.pending)
+
+	for f.tokens[f.pos].Id != tokenBracketClose {
+		if len(f.pending) > inner || f.tokens[f.pos].Id == tokenComment {
+			break
+		}
+
+		if f.peek().Id == tokenBraceOpen || f.peek().Id == tokenBracketOpen || f.peek().Id == tokenEOF {
+			break
+		}
+
+		switch f.peek().Id {
+		case tokenSpread:
+			f.next()
+			parts = append(parts, ".."+f.text(f.next()))
+		case tokenDoubleQuote:
+			parts = append(parts, f.stringText())
+		default:
+			parts = append(parts, f.text(f.next()))
+		}
+	}
+
+	var inline string
+	if len(parts) == 0 {
+		inline = "[]"
+	} else {
+		inline = "[ " + strings.Join(parts, " ") + " ]"
+	}
+
+	lineStart := strings.LastIndexByte(f.sb.String(), '\n') + 1
+	width := utf8.RuneCountInString(f.sb.String()[lineStart:]) + utf8.RuneCountInString(inline)
+
+	if f.tokens[f.pos].Id != tokenBracketClose || strings.Contains(inline, "\n") || width > formatLineWidth {
+		f.pos = start
+		f.prevEnd = prevEnd
+		f.pending = pending
+		return "", false
+	}
+
+	f.next() // ]
+	return inline, true
+}
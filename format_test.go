@@ -0,0 +1,121 @@
+package corn
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	input := `// header comment
+
+let { $a = 1 // trailing
+  $b={x=1}
+
+
+  // own line
+  $empty = {}
+} in {
+foo=$a    bar . baz   = [1 2 3]
+   ..$b
+  arr = [ { a = 1 } [ 1 ] ]
+  long = [ "aaaaaaaaaaaaaaaaaaa" "bbbbbbbbbbbbbbbbbbbbbbbbbbb" "ccccccccccccccccccccccccc" "ddddddd" ]
+  withcomment = [ 1 // one
+    2 ]
+  'quoted key' = "multi
+     line"
+  mid = // mid comment
+    0x1F
+  f = {}
+} // end
+`
+
+	expected := `// header comment
+
+let {
+    $a = 1 // trailing
+    $b = {
+        x = 1
+    }
+
+    // own line
+    $empty = {}
+} in {
+    foo = $a
+    bar.baz = [ 1 2 3 ]
+    ..$b
+    arr = [
+        {
+            a = 1
+        }
+        [ 1 ]
+    ]
+    long = [
+        "aaaaaaaaaaaaaaaaaaa"
+        "bbbbbbbbbbbbbbbbbbbbbbbbbbb"
+        "ccccccccccccccccccccccccc"
+        "ddddddd"
+    ]
+    withcomment = [
+        1 // one
+        2
+    ]
+    'quoted key' = "multi
+     line"
+    mid = 0x1F // mid comment
+    f = {}
+} // end
+`
+
+	output, err := Format(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, output, expected)
+
+	again, err := Format(output)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, again, output)
+
+	before, _ := Evaluate(input)
+	after, _ := Evaluate(output)
+
+	beforeJson, _ := json.Marshal(before.Value)
+	afterJson, _ := json.Marshal(after.Value)
+
+	assertEqual(t, string(afterJson), string(beforeJson))
+}
+
+func TestFormatInvalid(t *testing.T) {
+	if _, err := Format("{ a = }"); err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	f.Add("{ a = [ 1 2 ] // c\n b.c = { d = \"x $y\" } }")
+	f.Add("let { $y = \"z\" } in { ..$y a = [ ..$y { } ] }")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		output, err := Format(input)
+
+		if err != nil {
+			return
+		}
+
+		again, err := Format(output)
+
+		if err != nil {
+			t.Fatalf("formatted output is invalid: %v\n%s", err, output)
+		}
+
+		if again != output {
+			t.Fatalf("formatting is not idempotent:\n%s\n%s", output, again)
+		}
+	})
+}
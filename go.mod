@@ -2,9 +2,9 @@ module corn
 
 go 1.22
 
-require github.com/iancoleman/orderedmap v0.3.0
-
 require (
-	github.com/andreyvit/diff v0.0.0-20170406064948-c7f18ee00883 // indirect
-	github.com/sergi/go-diff v1.3.1 // indirect
+	github.com/andreyvit/diff v0.0.0-20170406064948-c7f18ee00883
+	github.com/iancoleman/orderedmap v0.3.0
 )
+
+require github.com/sergi/go-diff v1.3.1 // indirect
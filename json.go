@@ -0,0 +1,139 @@
+package corn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// FromJSON converts a JSON document into formatted Corn source.
+//
+// The document must be an object at the top level.
+// Key order is kept, and numbers without a fraction or exponent become integers.
+// Numbers which cannot be represented exactly as an int64 integer or within the range of a float64
+// are reported as errors naming their path.
+// Keys which are not valid bare path segments are quoted and strings are escaped,
+// and the result is laid out in the same way as `Format`.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeJSON(dec, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after top-level JSON value")
+	}
+
+	obj, ok := value.(*orderedmap.OrderedMap)
+
+	if !ok {
+		return nil, errors.New("cannot convert JSON " + describeValue(value) + " to Corn, expected object")
+	}
+
+	enc := &encoder{indent: formatIndent}
+	err = enc.writeObject(obj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := Format(enc.sb.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(output), nil
+}
+
+// Reads the next JSON value from the decoder,
+// using ordered maps for objects so that key order is kept.
+// `path` is the location of the value, used in errors.
+func decodeJSON(dec *json.Decoder, path []string) (Value, error) {
+	token, err := dec.Token()
+
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			obj := orderedmap.New()
+
+			for dec.More() {
+				keyToken, err := dec.Token()
+
+				if err != nil {
+					return nil, err
+				}
+
+				key := keyToken.(string)
+				value, err := decodeJSON(dec, append(path[:len(path):len(path)], key))
+
+				if err != nil {
+					return nil, err
+				}
+
+				obj.Set(key, value)
+			}
+
+			_, err = dec.Token()
+			return obj, err
+		case '[':
+			arr := []Value{}
+
+			for dec.More() {
+				index := strconv.Itoa(len(arr))
+				value, err := decodeJSON(dec, append(path[:len(path):len(path)], index))
+
+				if err != nil {
+					return nil, err
+				}
+
+				arr = append(arr, value)
+			}
+
+			_, err = dec.Token()
+			return arr, err
+		}
+	case json.Number:
+		str := token.String()
+
+		if !strings.ContainsAny(str, ".eE") {
+			num, err := strconv.ParseInt(str, 10, 64)
+
+			if err != nil {
+				return nil, errors.New("JSON number " + str + " at `" + joinPath(path) + "` overflows int64")
+			}
+
+			return num, nil
+		}
+
+		num, err := token.Float64()
+
+		if err != nil {
+			return nil, errors.New("JSON number " + str + " at `" + joinPath(path) + "` is out of range for float64")
+		}
+
+		return num, nil
+	case string, bool, nil:
+		return token, nil
+	}
+
+	return nil, errors.New("unexpected JSON token " + strconv.Quote(string(token.(json.Delim))))
+}
@@ -0,0 +1,86 @@
+package corn
+
+import (
+	"testing"
+)
+
+func TestFromJSON(t *testing.T) {
+	input := `{
+  "name": "app",
+  "version": 2,
+  "ratio": 1.5,
+  "big": 1e3,
+  "huge": 1.2345678901234568e+29,
+  "enabled": true,
+  "nothing": null,
+  "server.host": "localhost",
+  "price": "$5 \"each\"\n",
+  "": 0,
+  "ports": [80, 443],
+  "servers": [{"host": "a"}, {}],
+  "z": {"b": 1, "a": {}}
+}`
+
+	expected := `{
+    name = "app"
+    version = 2
+    ratio = 1.5
+    big = 1000.0
+    huge = 1.2345678901234568e+29
+    enabled = true
+    nothing = null
+    'server.host' = "localhost"
+    price = "\$5 \"each\"\n"
+    '' = 0
+    ports = [ 80 443 ]
+    servers = [
+        {
+            host = "a"
+        }
+        {}
+    ]
+    z = {
+        b = 1
+        a = {}
+    }
+}
+`
+
+	output, err := FromJSON([]byte(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), expected)
+
+	evaluation, err := Evaluate(string(output))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price, _ := evaluation.GetString("price")
+	assertEqual(t, price, "$5 \"each\"\n")
+
+	errs := map[string]string{
+		`{"a": [1, {"b": 1e400}]}`:      "JSON number 1e400 at `a.1.b` is out of range for float64",
+		`{"a b": 12345678901234567890}`: "JSON number 12345678901234567890 at `'a b'` overflows int64",
+	}
+
+	for input, expected := range errs {
+		_, err := FromJSON([]byte(input))
+
+		if err == nil {
+			t.Fatalf("expected error for %s", input)
+		}
+
+		assertEqual(t, err.Error(), expected)
+	}
+
+	for _, input := range []string{`[1]`, `{"a": 1} {}`, `{"a": }`, `{"a": 1`, ``, `{"a b\\": 1}`} {
+		if _, err := FromJSON([]byte(input)); err == nil {
+			t.Errorf("expected error for %s", input)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package corn
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// ErrLimitExceeded is wrapped by the `*EvalError` returned
+// when an input exceeds one of its `Limits`.
+// Use `errors.Is` to check for it.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bound the resources used to compile and evaluate an input,
+// for services which evaluate untrusted Corn.
+//
+// Each limit is disabled when zero.
+type Limits struct {
+	// The maximum length of the input in bytes.
+	MaxBytes int
+
+	// The maximum number of tokens in the input, excluding comments.
+	MaxTokens int
+
+	// The maximum nesting of objects and arrays.
+	// This is checked against the input before it is parsed,
+	// and again during evaluation, where each reference to an input
+	// declared in the `let` block also counts as a level.
+	//
+	// Nesting is always limited to `MaxNesting` levels, even when this is zero or larger,
+	// so that deeply nested input cannot exhaust the stack.
+	MaxDepth int
+
+	// The maximum number of values produced during evaluation.
+	// Inputs declared in the `let` block are counted each time they are referenced,
+	// and spreads count each value they copy.
+	MaxNodes int
+
+	// The maximum length in bytes of any string, including interpolated inputs,
+	// environment variables and strings within injected inputs.
+	MaxStringLength int
+}
+
+// MaxNesting is the nesting depth which is never exceeded regardless of `Limits.MaxDepth`,
+// as in `encoding/json`.
+// Exceeding it returns an error wrapping `ErrLimitExceeded`.
+const MaxNesting = 10000
+
+// Returns the nesting depth allowed by the limits.
+func (limits Limits) maxDepth() int {
+	if limits.MaxDepth > 0 {
+		return min(limits.MaxDepth, MaxNesting)
+	}
+
+	return MaxNesting
+}
+
+// Tracks the usage of an evaluation against its limits.
+// Shared by every copy of the `Evaluation`.
+type limiter struct {
+	Limits
+	nodes int
+}
+
+func limitError(span Span, msg string) *EvalError {
+	err := evalError(span, msg)
+	err.err = ErrLimitExceeded
+	return err
+}
+
+// Checks the size, token count and nesting of the input before it is parsed,
+// so that the parser cannot exhaust the stack.
+// Nesting is checked even without limits.
+func checkSourceLimits(tokens []Token[any], limits Limits) error {
+	// the last token is always EOF
+	if limits.MaxTokens > 0 && len(tokens)-1 > limits.MaxTokens {
+		return limitError(tokens[limits.MaxTokens].Span, "input exceeds the limit of "+strconv.Itoa(limits.MaxTokens)+" tokens")
+	}
+
+	depth, max := 0, limits.maxDepth()
+
+	for _, token := range tokens {
+		switch token.Id {
+		case tokenBraceOpen, tokenBracketOpen:
+			depth++
+
+			if depth > max {
+				return limitError(token.Span, depthMessage(max))
+			}
+		case tokenBraceClose, tokenBracketClose:
+			depth--
+		}
+	}
+
+	return nil
+}
+
+func depthMessage(max int) string {
+	return "nesting exceeds the limit of " + strconv.Itoa(max) + " levels"
+}
+
+// Returns a copy of the evaluation one level deeper,
+// or an error if this exceeds the depth limit.
+func (evaluation Evaluation) enter(span Span) (Evaluation, error) {
+	evaluation.depth++
+
+	if max := evaluation.limits.maxDepth(); evaluation.depth > max {
+		return evaluation, limitError(span, depthMessage(max))
+	}
+
+	return evaluation, nil
+}
+
+// Counts `n` values produced at `span` towards the node limit.
+func (evaluation Evaluation) produce(span Span, n int) error {
+	evaluation.limits.nodes += n
+
+	if max := evaluation.limits.MaxNodes; max > 0 && evaluation.limits.nodes > max {
+		return limitError(span, "result exceeds the limit of "+strconv.Itoa(max)+" values")
+	}
+
+	return nil
+}
+
+// Checks the length of a string against the string length limit.
+func (evaluation Evaluation) checkString(span Span, length int) error {
+	if max := evaluation.limits.MaxStringLength; max > 0 && length > max {
+		return limitError(span, "string exceeds the limit of "+strconv.Itoa(max)+" bytes")
+	}
+
+	return nil
+}
+
+// Checks every string within a value from outside the source,
+// such as an environment variable or an injected input, against the string length limit.
+func (evaluation Evaluation) checkStrings(span Span, value Value) error {
+	if evaluation.limits.MaxStringLength == 0 {
+		return nil
+	}
+
+	switch value := value.(type) {
+	case string:
+		return evaluation.checkString(span, len(value))
+	case *orderedmap.OrderedMap:
+		for _, k := range value.Keys() {
+			v, _ := value.Get(k)
+
+			if err := evaluation.checkStrings(span, v); err != nil {
+				return err
+			}
+		}
+	case []Value:
+		for _, v := range value {
+			if err := evaluation.checkStrings(span, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
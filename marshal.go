@@ -0,0 +1,624 @@
+package corn
+
+import (
+	"encoding"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// Marshal returns the Corn encoding of `v` on a single line.
+//
+// The top-level value must encode to an object,
+// so it must be a struct, a map with string keys or an `*orderedmap.OrderedMap`.
+//
+// Structs are encoded as objects using the same `corn` struct tags as `Unmarshal`.
+// The `omitempty` option skips zero values, as well as empty maps, slices and strings.
+// Map keys are sorted, while ordered maps keep their insertion order.
+// Slices and arrays are encoded as arrays,
+// and values implementing `encoding.TextMarshaler` are encoded as strings.
+//
+// Path segments which would not tokenize as a bare key are wrapped in single quotes,
+// and strings are escaped so that they evaluate back to the same value.
+// Values which contain themselves, and NaN or infinite floats,
+// are reported as an `*UnsupportedValueError`.
+func Marshal(v any) ([]byte, error) {
+	return marshal(v, &encoder{compact: true})
+}
+
+// MarshalIndent is like `Marshal` but places each key and array element on its own line.
+// Each line begins with `prefix`, followed by one copy of `indent` per level of nesting.
+func MarshalIndent(v any, prefix string, indent string) ([]byte, error) {
+	return marshal(v, &encoder{prefix: prefix, indent: indent})
+}
+
+// MarshalOptions configures `MarshalWithOptions`.
+type MarshalOptions struct {
+	// Prefix begins each line, as for `MarshalIndent`
+	Prefix string
+	// Indent is written once per level of nesting, as for `MarshalIndent`.
+	// If empty, the output is written on a single line as for `Marshal`.
+	Indent string
+
+	// ExtractInputs hoists repeated values into inputs in a `let` block.
+	//
+	// Strings, objects and arrays which appear at least `MinOccurrences` times
+	// are assigned to an input named after the key where they first appear,
+	// and each occurrence is replaced with a reference to it.
+	// Objects which start with the same keys and values as others
+	// have those keys replaced with a spread of an input holding them.
+	ExtractInputs bool
+	// MinOccurrences is the number of times a value must appear to be extracted, 2 if zero.
+	MinOccurrences int
+	// MinLength is the length a value must have when written compactly to be extracted, 8 if zero.
+	MinLength int
+}
+
+// MarshalWithOptions is like `Marshal` but allows the layout to be configured,
+// and repeated values to be extracted into inputs.
+func MarshalWithOptions(v any, options MarshalOptions) ([]byte, error) {
+	enc := &encoder{compact: options.Indent == "", prefix: options.Prefix, indent: options.Indent}
+
+	if !options.ExtractInputs {
+		return marshal(v, enc)
+	}
+
+	obj, err := topLevelObject(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	minOccurrences := options.MinOccurrences
+
+	if minOccurrences < 2 {
+		minOccurrences = 2
+	}
+
+	minLength := options.MinLength
+
+	if minLength <= 0 {
+		minLength = 8
+	}
+
+	inputs, values := extractInputs(obj, minOccurrences, minLength)
+
+	if len(inputs) > 0 {
+		err = enc.writeLet(inputs, values)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = enc.writeObject(obj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(enc.sb.String()), nil
+}
+
+func marshal(v any, enc *encoder) ([]byte, error) {
+	obj, err := topLevelObject(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.writeObject(obj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(enc.sb.String()), nil
+}
+
+func topLevelObject(v any) (*orderedmap.OrderedMap, error) {
+	value, err := valueOf(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := value.(*orderedmap.OrderedMap)
+
+	if !ok {
+		return nil, errors.New("cannot marshal " + describeValue(value) + " as top-level value, expected object")
+	}
+
+	return obj, nil
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// An UnsupportedValueError is returned when marshalling a value which cannot be represented in Corn,
+// such as a NaN float or a value which contains itself.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Path  string // dotted path to the value within the object
+	Str   string // description of the value
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "cannot marshal `" + e.Path + "`: unsupported value: " + e.Str
+}
+
+// Converts an arbitrary Go value into the same representation `Evaluate` produces,
+// using ordered maps for objects and `[]Value` for arrays.
+func valueOf(v any) (Value, error) {
+	return reflectValue(reflect.ValueOf(v), "")
+}
+
+func reflectValue(rv reflect.Value, path string) (Value, error) {
+	c := &converter{seen: make(map[visit]bool)}
+	return c.value(rv, path)
+}
+
+// Converts Go values into Corn values,
+// tracking the pointers, maps and slices being converted to detect cycles.
+type converter struct {
+	seen map[visit]bool
+}
+
+// Identifies a pointer, map or slice by its address and type,
+// and for slices also by length, as slices of an array share its address.
+type visit struct {
+	ptr    unsafe.Pointer
+	typ    reflect.Type
+	length int
+}
+
+// Marks the pointer, map or slice as being converted,
+// returning an error if it contains itself,
+// and a function to call once its conversion is complete.
+func (c *converter) enter(rv reflect.Value, path string) (func(), error) {
+	key := visit{ptr: rv.UnsafePointer(), typ: rv.Type()}
+
+	if rv.Kind() == reflect.Slice {
+		key.length = rv.Len()
+	}
+
+	if c.seen[key] {
+		return nil, &UnsupportedValueError{Value: rv, Path: path, Str: "encountered a cycle via " + rv.Type().String()}
+	}
+
+	c.seen[key] = true
+
+	return func() { delete(c.seen, key) }, nil
+}
+
+func (c *converter) value(rv reflect.Value, path string) (Value, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	if rv.Type().Implements(textMarshalerType) {
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, nil
+		}
+
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+
+		if err != nil {
+			return nil, errors.New("cannot marshal `" + path + "`: " + err.Error())
+		}
+
+		return string(text), nil
+	}
+
+	switch rv.Type() {
+	case orderedMapType:
+		obj := reflect.New(orderedMapType)
+		obj.Elem().Set(rv)
+
+		return c.orderedMap(obj.Interface().(*orderedmap.OrderedMap), path)
+	case reflect.PointerTo(orderedMapType):
+		if rv.IsNil() {
+			return nil, nil
+		}
+
+		leave, err := c.enter(rv, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer leave()
+
+		return c.orderedMap(rv.Interface().(*orderedmap.OrderedMap), path)
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+
+		if rv.Kind() == reflect.Pointer {
+			leave, err := c.enter(rv, path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			defer leave()
+		}
+
+		return c.value(rv.Elem(), path)
+	case reflect.Struct:
+		obj := orderedmap.New()
+
+		for _, field := range typeFields(rv.Type()) {
+			fieldValue, ok := fieldByIndexRead(rv, field.index)
+
+			if !ok || (field.omitEmpty && isEmptyValue(fieldValue)) {
+				continue
+			}
+
+			value, err := c.value(fieldValue, keyPath(path, field.name))
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj.Set(field.name, value)
+		}
+
+		return obj, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+
+		leave, err := c.enter(rv, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer leave()
+
+		keys := make([]string, 0, rv.Len())
+		values := make(map[string]reflect.Value, rv.Len())
+
+		for iter := rv.MapRange(); iter.Next(); {
+			var key string
+
+			switch iter.Key().Kind() {
+			case reflect.String:
+				key = iter.Key().String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				key = strconv.FormatInt(iter.Key().Int(), 10)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				key = strconv.FormatUint(iter.Key().Uint(), 10)
+			default:
+				return nil, errors.New("cannot marshal map with key type " + rv.Type().Key().String() + " at `" + path + "`")
+			}
+
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+
+		slices.Sort(keys)
+
+		obj := orderedmap.New()
+
+		for _, key := range keys {
+			value, err := c.value(values[key], keyPath(path, key))
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj.Set(key, value)
+		}
+
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice {
+			if rv.IsNil() {
+				return nil, nil
+			}
+
+			leave, err := c.enter(rv, path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			defer leave()
+		}
+
+		arr := make([]Value, rv.Len())
+
+		for i := range arr {
+			value, err := c.value(rv.Index(i), indexPath(path, i))
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr[i] = value
+		}
+
+		return arr, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		num := rv.Uint()
+
+		if num > math.MaxInt64 {
+			return nil, errors.New("cannot marshal `" + path + "`: integer " + strconv.FormatUint(num, 10) + " overflows int64")
+		}
+
+		return int64(num), nil
+	case reflect.Float32, reflect.Float64:
+		num := rv.Float()
+
+		if math.IsNaN(num) || math.IsInf(num, 0) {
+			return nil, &UnsupportedValueError{Value: rv, Path: path, Str: strconv.FormatFloat(num, 'g', -1, 64)}
+		}
+
+		return num, nil
+	default:
+		return nil, errors.New("cannot marshal `" + path + "`: unsupported type " + rv.Type().String())
+	}
+}
+
+// Copies an ordered map, converting each of its values.
+// This ensures nested Go values are normalised into Corn values.
+func (c *converter) orderedMap(obj *orderedmap.OrderedMap, path string) (*orderedmap.OrderedMap, error) {
+	out := orderedmap.New()
+
+	for _, key := range obj.Keys() {
+		v, _ := obj.Get(key)
+		value, err := c.value(reflect.ValueOf(v), keyPath(path, key))
+
+		if err != nil {
+			return nil, err
+		}
+
+		out.Set(key, value)
+	}
+
+	return out, nil
+}
+
+// Like `fieldByIndex` but for reading,
+// reporting false if the field is behind a nil embedded pointer.
+func fieldByIndexRead(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			rv = rv.Elem()
+		}
+
+		rv = rv.Field(x)
+	}
+
+	return rv, true
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+// Writes Corn values as source text.
+type encoder struct {
+	sb      strings.Builder
+	compact bool
+	prefix  string
+	indent  string
+	depth   int
+
+	// names of inputs to write in place of values, keyed by the id of the value in `values`
+	inputs map[int]string
+	values *valueTable
+}
+
+func (enc *encoder) newline() {
+	if enc.compact {
+		enc.sb.WriteRune(' ')
+		return
+	}
+
+	enc.sb.WriteRune('\n')
+	enc.sb.WriteString(enc.prefix)
+
+	for i := 0; i < enc.depth; i++ {
+		enc.sb.WriteString(enc.indent)
+	}
+}
+
+func (enc *encoder) writeValue(value Value) error {
+	if name, ok := enc.inputFor(value); ok {
+		enc.sb.WriteString(name)
+		return nil
+	}
+
+	switch value := value.(type) {
+	case nil:
+		enc.sb.WriteString("null")
+	case *orderedmap.OrderedMap:
+		return enc.writeObject(value)
+	case []Value:
+		return enc.writeArray(value)
+	case string:
+		enc.sb.WriteString(quoteString(value))
+	case bool:
+		enc.sb.WriteString(strconv.FormatBool(value))
+	case int64:
+		enc.sb.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		enc.sb.WriteString(formatFloat(value))
+	default:
+		return errors.New("cannot write value of type " + reflect.TypeOf(value).String())
+	}
+
+	return nil
+}
+
+func (enc *encoder) writeObject(obj *orderedmap.OrderedMap) error {
+	keys := obj.Keys()
+
+	if len(keys) == 0 {
+		enc.sb.WriteString("{}")
+		return nil
+	}
+
+	enc.sb.WriteRune('{')
+	enc.depth++
+
+	if name, count := enc.spreadFor(obj); count > 0 {
+		enc.newline()
+		enc.sb.WriteString(".." + name)
+		keys = keys[count:]
+	}
+
+	for _, key := range keys {
+		segment, err := quotePathSegment(key)
+
+		if err != nil {
+			return err
+		}
+
+		enc.newline()
+		enc.sb.WriteString(segment)
+		enc.sb.WriteString(" = ")
+
+		value, _ := obj.Get(key)
+		err = enc.writeValue(value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	enc.depth--
+	enc.newline()
+	enc.sb.WriteRune('}')
+
+	return nil
+}
+
+func (enc *encoder) writeArray(arr []Value) error {
+	if len(arr) == 0 {
+		enc.sb.WriteString("[]")
+		return nil
+	}
+
+	enc.sb.WriteRune('[')
+	enc.depth++
+
+	for _, value := range arr {
+		enc.newline()
+		err := enc.writeValue(value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	enc.depth--
+	enc.newline()
+	enc.sb.WriteRune(']')
+
+	return nil
+}
+
+// Returns the key as a path segment,
+// wrapping it in single quotes if it would not otherwise tokenize as a single segment.
+func quotePathSegment(key string) (string, error) {
+	needsQuotes := key == "" ||
+		strings.ContainsAny(key, charsInvalidPath) ||
+		strings.ContainsAny(key[:1], "$'}") ||
+		strings.HasPrefix(key, "//")
+
+	if !needsQuotes {
+		return key, nil
+	}
+
+	if strings.HasSuffix(key, "\\") || strings.Contains(key, "\\'") {
+		return "", errors.New("cannot represent key `" + key + "` as a quoted path segment")
+	}
+
+	return "'" + strings.ReplaceAll(key, "'", "\\'") + "'", nil
+}
+
+// Returns the string as a double-quoted Corn string literal.
+//
+// Newlines and other control characters are always escaped,
+// which also prevents the multiline un-indenting from applying on evaluation.
+func quoteString(str string) string {
+	sb := new(strings.Builder)
+	sb.WriteRune('"')
+
+	for _, r := range str {
+		switch r {
+		case '\\':
+			sb.WriteString("\\\\")
+		case '"':
+			sb.WriteString("\\\"")
+		case '$':
+			sb.WriteString("\\$")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\t':
+			sb.WriteString("\\t")
+		default:
+			if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+				code := strconv.FormatInt(int64(r), 16)
+				sb.WriteString("\\u" + strings.Repeat("0", 4-len(code)) + code)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+
+	sb.WriteRune('"')
+	return sb.String()
+}
+
+// Formats a float so that it always tokenizes as a float,
+// which requires a decimal point before any exponent.
+func formatFloat(num float64) string {
+	str := strconv.FormatFloat(num, 'g', -1, 64)
+
+	mantissa, exponent, hasExponent := strings.Cut(str, "e")
+
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+
+	if hasExponent {
+		return mantissa + "e" + exponent
+	}
+
+	return mantissa
+}
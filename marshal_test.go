@@ -0,0 +1,290 @@
+package corn
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+func TestMarshal(t *testing.T) {
+	obj := orderedmap.New()
+	obj.Set("b", int64(2))
+	obj.Set("a", []any{1, "x", nil})
+
+	value := map[string]any{
+		"string":  "quote \" slash \\ dollar $foo\nnew line\ttab",
+		"int":     -42,
+		"float":   1.5,
+		"big":     1e21,
+		"small":   math.SmallestNonzeroFloat64,
+		"bool":    true,
+		"null":    nil,
+		"ordered": obj,
+		"empty":   map[string]any{},
+		"array":   []any{},
+		"dot.key": "quoted",
+		"with space": map[string]any{
+			"'quote'": 1,
+			"$input":  2,
+			"}":       3,
+			"//":      4,
+			"":        5,
+			"a\\b":    6,
+		},
+	}
+
+	output, err := Marshal(value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{ array = [] big = 1.0e+21 bool = true 'dot.key' = "quoted" empty = {} float = 1.5 int = -42 null = null ordered = { b = 2 a = [ 1 "x" null ] } small = 5.0e-324 string = "quote \" slash \\ dollar \$foo\nnew line\ttab" 'with space' = { '' = 5 '$input' = 2 '\'quote\'' = 1 '//' = 4 a\b = 6 '}' = 3 } }`
+	assertEqual(t, string(output), expected)
+
+	assertRoundTrip(t, value, string(output))
+}
+
+func TestMarshalIndent(t *testing.T) {
+	type server struct {
+		Host string `corn:"host"`
+		Port int    `corn:"port,omitempty"`
+	}
+
+	value := struct {
+		Name    string   `corn:"name"`
+		Servers []server `corn:"servers"`
+	}{
+		Name:    "test",
+		Servers: []server{{Host: "a", Port: 80}, {Host: "b"}},
+	}
+
+	output, err := MarshalIndent(value, "", "    ")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+    name = "test"
+    servers = [
+        {
+            host = "a"
+            port = 80
+        }
+        {
+            host = "b"
+        }
+    ]
+}`
+	assertEqual(t, string(output), expected)
+
+	assertRoundTrip(t, value, string(output))
+}
+
+type testNode struct {
+	Next *testNode
+}
+
+func TestMarshalErrors(t *testing.T) {
+	node := &testNode{}
+	node.Next = node
+
+	cyclicMap := map[string]any{}
+	cyclicMap["self"] = cyclicMap
+
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+
+	cyclicObj := orderedmap.New()
+	cyclicObj.Set("self", cyclicObj)
+
+	inputs := []any{
+		42,
+		nil,
+		map[string]any{"nan": math.NaN()},
+		map[string]any{"big": uint64(math.MaxUint64)},
+		map[string]any{"fn": func() {}},
+	}
+
+	for _, input := range inputs {
+		if _, err := Marshal(input); err == nil {
+			t.Errorf("expected error marshalling %v", input)
+		}
+	}
+
+	cycles := map[string]any{
+		"cannot marshal `Next`: unsupported value: encountered a cycle via *corn.testNode":          node,
+		"cannot marshal `self`: unsupported value: encountered a cycle via map[string]interface {}": cyclicMap,
+		"cannot marshal `a[0]`: unsupported value: encountered a cycle via []interface {}":          map[string]any{"a": cyclicSlice},
+		"cannot marshal `self`: unsupported value: encountered a cycle via *orderedmap.OrderedMap":  cyclicObj,
+	}
+
+	for expected, input := range cycles {
+		_, err := Marshal(input)
+
+		var unsupportedErr *UnsupportedValueError
+		if !errors.As(err, &unsupportedErr) {
+			t.Fatalf("expected UnsupportedValueError, got %v", err)
+		}
+
+		assertEqual(t, err.Error(), expected)
+	}
+
+	_, err := EvaluateWithOptions(`{ a = $node }`, Options{Inputs: map[string]any{"node": node}})
+
+	if err == nil {
+		t.Fatal("expected error for cyclic input")
+	}
+
+	// values reached more than once without a cycle are not rejected
+	shared := &testNode{}
+	output, err := Marshal(map[string]any{"a": shared, "b": []*testNode{shared, shared}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), `{ a = { Next = null } b = [ { Next = null } { Next = null } ] }`)
+}
+
+// Checks that evaluating the Corn output produces the same JSON as the original value.
+func TestMarshalExtractInputs(t *testing.T) {
+	input := `{
+    services = {
+        api = { image = "registry.local/app:1.2.3" replicas = 3 port = 8080 }
+        worker = { image = "registry.local/app:1.2.3" replicas = 3 port = 9090 }
+        cron = { image = "registry.local/app:1.2.3" replicas = 3 }
+    }
+    hosts = [ "db.internal.example" "db.internal.example" ]
+    env_name = [ "production-cluster" "production-cluster" ]
+    short = [ "a" "a" "a" ]
+}`
+
+	expected := `let {
+    $value_env_name = "production-cluster"
+    $hosts = "db.internal.example"
+    $api_base = {
+        image = "registry.local/app:1.2.3"
+        replicas = 3
+    }
+} in {
+    services = {
+        api = {
+            ..$api_base
+            port = 8080
+        }
+        worker = {
+            ..$api_base
+            port = 9090
+        }
+        cron = $api_base
+    }
+    hosts = [
+        $hosts
+        $hosts
+    ]
+    env_name = [
+        $value_env_name
+        $value_env_name
+    ]
+    short = [
+        "a"
+        "a"
+        "a"
+    ]
+}`
+
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := MarshalWithOptions(evaluation.Value, MarshalOptions{Indent: "    ", ExtractInputs: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), expected)
+	assertRoundTrip(t, evaluation.Value, string(output))
+
+	// nothing is repeated three times
+	output, err = MarshalWithOptions(evaluation.Value, MarshalOptions{ExtractInputs: true, MinOccurrences: 4})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact, _ := Marshal(evaluation.Value)
+	assertEqual(t, string(output), string(compact))
+}
+
+// Returns an object of `n` services sharing a handful of values,
+// large enough that extracting inputs must not be quadratic.
+func repeatedServices(n int) *orderedmap.OrderedMap {
+	services := orderedmap.New()
+
+	for i := 0; i < n; i++ {
+		service := orderedmap.New()
+		service.Set("image", "registry.example.com/service-"+strconv.Itoa(i%7))
+		service.Set("region", []string{"eu-west-1", "us-east-1", "ap-south-1"}[i%3])
+		service.Set("replicas", int64(i%4))
+		service.Set("port", int64(8000+i))
+
+		logging := orderedmap.New()
+		logging.Set("driver", "json-file")
+		logging.Set("level", []string{"debug", "info"}[i%2])
+		service.Set("logging", logging)
+
+		services.Set("service-"+strconv.Itoa(i), service)
+	}
+
+	return services
+}
+
+func TestMarshalExtractInputsLarge(t *testing.T) {
+	value := repeatedServices(2000)
+	output, err := MarshalWithOptions(value, MarshalOptions{ExtractInputs: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact, _ := Marshal(value)
+
+	if len(output) >= len(compact) {
+		t.Errorf("expected extracting inputs to shorten the output, got %d bytes from %d", len(output), len(compact))
+	}
+
+	assertRoundTrip(t, value, string(output))
+}
+
+func BenchmarkMarshalExtractInputs(b *testing.B) {
+	value := repeatedServices(500)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalWithOptions(value, MarshalOptions{ExtractInputs: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func assertRoundTrip(t *testing.T, value any, output string) {
+	evaluation, err := Evaluate(output)
+
+	if err != nil {
+		t.Fatalf("failed to evaluate %s: %v", output, err)
+	}
+
+	expected, _ := valueOf(value)
+	expectedJson, _ := json.Marshal(expected)
+	actualJson, _ := json.Marshal(evaluation.Value)
+
+	assertEqual(t, string(actualJson), string(expectedJson))
+}
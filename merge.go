@@ -0,0 +1,147 @@
+package corn
+
+import (
+	"github.com/iancoleman/orderedmap"
+)
+
+// ArrayMerge selects how arrays are combined when deep-merging objects.
+type ArrayMerge int
+
+const (
+	// The later array replaces the earlier one.
+	ArrayReplace ArrayMerge = iota
+	// The elements of the later array are appended to the earlier one.
+	ArrayAppend
+)
+
+// MergeOptions configure how objects are deep-merged,
+// both by `Merge` and during evaluation when set in `Options.Merge`.
+//
+// When a key is present in both objects, objects are merged recursively
+// and arrays are combined according to `Arrays`.
+// Any other value, or values of different types, replace the earlier value.
+// Keys keep the position in which they first appear.
+type MergeOptions struct {
+	Arrays ArrayMerge
+}
+
+// Merge returns a deep merge of `b` over `a`, replacing arrays.
+// Neither object is modified, and a nil object is treated as empty.
+func Merge(a, b *orderedmap.OrderedMap) *orderedmap.OrderedMap {
+	return MergeOptions{}.Merge(a, b)
+}
+
+// Merge returns a deep merge of `b` over `a` using the options.
+// Neither object is modified, and a nil object is treated as empty.
+func (options MergeOptions) Merge(a, b *orderedmap.OrderedMap) *orderedmap.OrderedMap {
+	if a == nil {
+		a = orderedmap.New()
+	}
+
+	if b == nil {
+		b = orderedmap.New()
+	}
+
+	return options.merge(copyValue(a), copyValue(b)).(*orderedmap.OrderedMap)
+}
+
+// Merges `value` over `existing`, reusing and modifying `existing` where possible.
+func (options MergeOptions) merge(existing Value, value Value) Value {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		if obj, ok := existing.(*orderedmap.OrderedMap); ok {
+			for _, k := range value.Keys() {
+				v, _ := value.Get(k)
+
+				if old, ok := obj.Get(k); ok {
+					v = options.merge(old, v)
+				}
+
+				obj.Set(k, v)
+			}
+
+			return obj
+		}
+	case []Value:
+		if arr, ok := existing.([]Value); ok && options.Arrays == ArrayAppend {
+			return append(arr, value...)
+		}
+	}
+
+	return value
+}
+
+// Merges the origins of `value` over those of `existing` in the same way as `merge`,
+// which must not yet have been called on the values.
+func (options MergeOptions) mergeOrigins(existing Value, value Value, existingNode *originNode, node *originNode) *originNode {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		if obj, ok := existing.(*orderedmap.OrderedMap); ok {
+			for _, k := range value.Keys() {
+				v, _ := value.Get(k)
+				child := node.child(k)
+
+				if old, ok := obj.Get(k); ok {
+					child = options.mergeOrigins(old, v, existingNode.child(k), child)
+				}
+
+				existingNode.setChild(k, child)
+			}
+
+			return existingNode
+		}
+	case []Value:
+		if arr, ok := existing.([]Value); ok && options.Arrays == ArrayAppend {
+			elements := make([]*originNode, 0, len(arr)+len(value))
+
+			for i := range arr {
+				elements = append(elements, existingNode.element(i))
+			}
+
+			for i := range value {
+				elements = append(elements, node.element(i))
+			}
+
+			existingNode.elements = elements
+			return existingNode
+		}
+	}
+
+	return node
+}
+
+// Returns the value to store in place of `existing`, if it exists,
+// deep-merging `value` over it if enabled by `Options.Merge`.
+// `node` holds the origins of `value`, and is updated to those of the result.
+func (evaluation Evaluation) mergeValue(existing Value, exists bool, value Value, existingNode *originNode, node *originNode) Value {
+	if evaluation.merge == nil || !exists {
+		return value
+	}
+
+	if node != nil {
+		*node = *evaluation.merge.mergeOrigins(existing, value, existingNode, node)
+	}
+
+	return evaluation.merge.merge(existing, value)
+}
+
+// Returns the value at the path within the object, if every segment exists.
+func getAtPath(obj *orderedmap.OrderedMap, path []string) (Value, bool) {
+	var value Value = obj
+
+	for _, seg := range path {
+		obj, ok := value.(*orderedmap.OrderedMap)
+
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = obj.Get(seg)
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,86 @@
+package corn
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base, err := Evaluate(`{ db = { host = "localhost" port = 5432 tags = [ "a" ] } debug = false }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	override, err := Evaluate(`{ db = { port = 6543 tags = [ "b" ] } debug = { level = 1 } name = "app" }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, _ := json.Marshal(Merge(base.Value, override.Value))
+	assertEqual(t, string(merged), `{"db":{"host":"localhost","port":6543,"tags":["b"]},"debug":{"level":1},"name":"app"}`)
+
+	merged, _ = json.Marshal(MergeOptions{Arrays: ArrayAppend}.Merge(base.Value, override.Value))
+	assertEqual(t, string(merged), `{"db":{"host":"localhost","port":6543,"tags":["a","b"]},"debug":{"level":1},"name":"app"}`)
+
+	original, _ := json.Marshal(base.Value)
+	assertEqual(t, string(original), `{"db":{"host":"localhost","port":5432,"tags":["a"]},"debug":false}`)
+
+	merged, _ = json.Marshal(Merge(nil, override.Value))
+	assertEqual(t, string(merged), `{"db":{"port":6543,"tags":["b"]},"debug":{"level":1},"name":"app"}`)
+
+	merged, _ = json.Marshal(Merge(base.Value, nil))
+	assertEqual(t, string(merged), string(original))
+
+	merged, _ = json.Marshal(Merge(nil, nil))
+	assertEqual(t, string(merged), `{}`)
+}
+
+func TestDeepMergeEvaluation(t *testing.T) {
+	input := `let {
+    $base = { db = { host = "localhost" port = 5432 } tags = [ "a" ] }
+    $override = { db = { port = 6543 } tags = [ "b" ] }
+} in {
+    ..$base
+    ..$override
+    db = { user = "app" }
+    db.pool.size = 10
+}`
+
+	tests := []struct {
+		options  Options
+		expected string
+	}{
+		{Options{}, `{"db":{"user":"app","pool":{"size":10}},"tags":["b"]}`},
+		{Options{Merge: &MergeOptions{}}, `{"db":{"host":"localhost","port":6543,"user":"app","pool":{"size":10}},"tags":["b"]}`},
+		{Options{Merge: &MergeOptions{Arrays: ArrayAppend}}, `{"db":{"host":"localhost","port":6543,"user":"app","pool":{"size":10}},"tags":["a","b"]}`},
+	}
+
+	for _, test := range tests {
+		evaluation, err := EvaluateWithOptions(input, test.options)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output, _ := json.Marshal(evaluation.Value)
+		assertEqual(t, string(output), test.expected)
+	}
+
+	evaluation, err := EvaluateWithOptions(input, Options{Merge: &MergeOptions{Arrays: ArrayAppend}, Provenance: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{
+		"db.host": "$base",
+		"db.port": "$override",
+		"db.user": "",
+		"tags.0":  "$base",
+		"tags.1":  "$override",
+	} {
+		assertEqual(t, evaluation.Provenance[path].Input, expected)
+	}
+}
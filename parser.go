@@ -1,7 +1,6 @@
 package corn
 
 import (
-	"errors"
 	"fmt"
 )
 
@@ -32,6 +31,7 @@ type Rule[T comparable] struct {
 	Id    ruleId
 	Rules []Rule[any]
 	Data  *T
+	Span  Span
 }
 
 func (r Rule[Stringer]) String() string {
@@ -93,7 +93,7 @@ func parseAssignBlock(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	var brace = tokens[0]
 	if brace.Id != tokenBraceOpen {
-		return Rule[any]{}, tokens, errors.New("expected `{`, got " + brace.String())
+		return Rule[any]{}, tokens, syntaxError(brace.Span, "expected `{`, got "+brace.String())
 	}
 
 	tokens = tokens[1:]
@@ -102,7 +102,7 @@ func parseAssignBlock(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	for token.Id != tokenBraceClose {
 
 		if token.Id != tokenInput {
-			return Rule[any]{}, tokens, errors.New("expected `input`, got " + token.String())
+			return Rule[any]{}, tokens, syntaxError(token.Span, "expected `input`, got "+token.String())
 		}
 
 		var assignment Rule[any]
@@ -118,23 +118,25 @@ func parseAssignBlock(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	var closeBrace = tokens[0]
 	if closeBrace.Id != tokenBraceClose {
-		return Rule[any]{}, tokens, errors.New("expected `}`, got " + closeBrace.String())
+		return Rule[any]{}, tokens, syntaxError(closeBrace.Span, "expected `}`, got "+closeBrace.String())
 	}
 
 	var in = tokens[1]
 	if in.Id != tokenIn {
-		return Rule[any]{}, tokens, errors.New("expected `in`, got " + in.String())
+		return Rule[any]{}, tokens, syntaxError(in.Span, "expected `in`, got "+in.String())
 	}
 
 	tokens = tokens[2:]
 
+	rule.Span = Span{Start: brace.Span.Start, End: in.Span.End}
+
 	return rule, tokens, nil
 }
 
 func parseAssignment(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	if len(tokens) < 3 {
-		return Rule[any]{}, tokens, errors.New("unexpected end of input")
+		return Rule[any]{}, tokens, syntaxError(tokens[len(tokens)-1].Span, "unexpected end of input")
 	}
 
 	var input = tokens[0]
@@ -142,11 +144,11 @@ func parseAssignment(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	var value = tokens[2]
 
 	if input.Id != tokenInput {
-		return Rule[any]{}, tokens, errors.New("expected `input`, got " + input.String())
+		return Rule[any]{}, tokens, syntaxError(input.Span, "expected `input`, got "+input.String())
 	}
 
 	if equals.Id != tokenEquals {
-		return Rule[any]{}, tokens, errors.New("expected `=`, got " + input.String())
+		return Rule[any]{}, tokens, syntaxError(equals.Span, "expected `=`, got "+equals.String())
 	}
 
 	if value.Id != tokenBraceOpen &&
@@ -158,7 +160,7 @@ func parseAssignment(tokens []Token[any]) (Rule[any], []Token[any], error) {
 		value.Id != tokenInteger &&
 		value.Id != tokenDoubleQuote &&
 		value.Id != tokenInput {
-		return Rule[any]{}, tokens, errors.New("expected one of `{`, `[`, `true`, `false`, `null`, `float`, `integer`, `\", `input`, got " + input.String())
+		return Rule[any]{}, tokens, syntaxError(value.Span, "expected one of `{`, `[`, `true`, `false`, `null`, `float`, `integer`, `\"`, `input`, got "+value.String())
 	}
 
 	valueRule, tokens, err := parseValue(tokens[2:])
@@ -166,9 +168,9 @@ func parseAssignment(tokens []Token[any]) (Rule[any], []Token[any], error) {
 		return Rule[any]{}, tokens, err
 	}
 
-	return Rule[any]{Id: ruleAssignment, Rules: []Rule[any]{
-		{Id: ruleInput, Data: input.Data},
-		{Id: ruleValue, Rules: []Rule[any]{valueRule}},
+	return Rule[any]{Id: ruleAssignment, Span: Span{Start: input.Span.Start, End: valueRule.Span.End}, Rules: []Rule[any]{
+		{Id: ruleInput, Data: input.Data, Span: input.Span},
+		{Id: ruleValue, Rules: []Rule[any]{valueRule}, Span: valueRule.Span},
 	}}, tokens, nil
 }
 
@@ -177,19 +179,19 @@ func parseValue(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	switch token.Id {
 	case tokenFloat:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleFloat, Data: token.Data}, tokens, nil
+		return Rule[any]{Id: ruleFloat, Data: token.Data, Span: token.Span}, tokens, nil
 	case tokenInteger:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleInteger, Data: token.Data}, tokens, nil
+		return Rule[any]{Id: ruleInteger, Data: token.Data, Span: token.Span}, tokens, nil
 	case tokenInput:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleInput, Data: token.Data}, tokens, nil
+		return Rule[any]{Id: ruleInput, Data: token.Data, Span: token.Span}, tokens, nil
 	case tokenTrue:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleBoolean, Data: pointer[any](true)}, tokens, nil
+		return Rule[any]{Id: ruleBoolean, Data: pointer[any](true), Span: token.Span}, tokens, nil
 	case tokenFalse:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleBoolean, Data: pointer[any](false)}, tokens, nil
+		return Rule[any]{Id: ruleBoolean, Data: pointer[any](false), Span: token.Span}, tokens, nil
 	case tokenDoubleQuote:
 		return parseString(tokens)
 	case tokenBraceOpen:
@@ -198,9 +200,9 @@ func parseValue(tokens []Token[any]) (Rule[any], []Token[any], error) {
 		return parseArray(tokens)
 	case tokenNull:
 		tokens = tokens[1:]
-		return Rule[any]{Id: ruleNull}, tokens, nil
+		return Rule[any]{Id: ruleNull, Span: token.Span}, tokens, nil
 	default:
-		return Rule[any]{}, tokens, errors.New("expected `value`, got " + token.String())
+		return Rule[any]{}, tokens, syntaxError(token.Span, "expected `value`, got "+token.String())
 	}
 }
 
@@ -211,7 +213,7 @@ func pointer[T any](data T) *T {
 func parseObject(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	var openBrace = tokens[0]
 	if openBrace.Id != tokenBraceOpen {
-		return Rule[any]{}, tokens, errors.New("expected `{`, got + " + openBrace.String())
+		return Rule[any]{}, tokens, syntaxError(openBrace.Span, "expected `{`, got "+openBrace.String())
 	}
 
 	tokens = tokens[1:]
@@ -229,7 +231,7 @@ func parseObject(tokens []Token[any]) (Rule[any], []Token[any], error) {
 		case tokenPathSegment:
 			pair, tokens, err = parsePair(tokens)
 		default:
-			return Rule[any]{}, tokens, errors.New("expected one of `..` or `path_seg`, got " + token.String())
+			return Rule[any]{}, tokens, syntaxError(token.Span, "expected one of `..` or `path_seg`, got "+token.String())
 		}
 
 		if err != nil {
@@ -243,28 +245,30 @@ func parseObject(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	var closeBrace = tokens[0]
 	if closeBrace.Id != tokenBraceClose {
-		return Rule[any]{}, tokens, errors.New("expected `}`, got " + closeBrace.String())
+		return Rule[any]{}, tokens, syntaxError(closeBrace.Span, "expected `}`, got "+closeBrace.String())
 	}
 
 	tokens = tokens[1:]
 
+	rule.Span = Span{Start: openBrace.Span.Start, End: closeBrace.Span.End}
+
 	return rule, tokens, nil
 }
 
 func parseSpread(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	spread := tokens[0]
 	if spread.Id != tokenSpread {
-		return Rule[any]{}, tokens, errors.New("expected `..`, got " + spread.String())
+		return Rule[any]{}, tokens, syntaxError(spread.Span, "expected `..`, got "+spread.String())
 	}
 
 	input := tokens[1]
 	if input.Id != tokenInput {
-		return Rule[any]{}, tokens, errors.New("expected `input`, got " + input.String())
+		return Rule[any]{}, tokens, syntaxError(input.Span, "expected `input`, got "+input.String())
 	}
 
 	tokens = tokens[2:]
 
-	rule := Rule[any]{Id: ruleSpread, Data: input.Data}
+	rule := Rule[any]{Id: ruleSpread, Data: input.Data, Span: Span{Start: spread.Span.Start, End: input.Span.End}}
 	return rule, tokens, nil
 }
 
@@ -279,7 +283,7 @@ func parsePair(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	var eq = tokens[0]
 	if eq.Id != tokenEquals {
-		return Rule[any]{}, tokens, errors.New("expected `=`, got " + eq.String())
+		return Rule[any]{}, tokens, syntaxError(eq.Span, "expected `=`, got "+eq.String())
 	}
 
 	tokens = tokens[1:]
@@ -291,6 +295,7 @@ func parsePair(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	}
 
 	rule.Rules = append(rule.Rules, value)
+	rule.Span = Span{Start: path.Span.Start, End: value.Span.End}
 
 	return rule, tokens, nil
 }
@@ -299,13 +304,14 @@ func parsePath(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	var path_seg = tokens[0]
 
 	if path_seg.Id != tokenPathSegment {
-		return Rule[any]{}, tokens, errors.New("expected `path_seg`, got " + path_seg.String())
+		return Rule[any]{}, tokens, syntaxError(path_seg.Span, "expected `path_seg`, got "+path_seg.String())
 	}
 
-	var path = Rule[any]{Id: rulePath}
+	var path = Rule[any]{Id: rulePath, Span: path_seg.Span}
 
 	for path_seg.Id == tokenPathSegment {
-		path.Rules = append(path.Rules, Rule[any]{Id: rulePathSegment, Data: path_seg.Data})
+		path.Rules = append(path.Rules, Rule[any]{Id: rulePathSegment, Data: path_seg.Data, Span: path_seg.Span})
+		path.Span.End = path_seg.Span.End
 
 		var dot = tokens[1]
 		if dot.Id == tokenPathSeparator {
@@ -323,7 +329,7 @@ func parsePath(tokens []Token[any]) (Rule[any], []Token[any], error) {
 func parseArray(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	var openBracket = tokens[0]
 	if openBracket.Id != tokenBracketOpen {
-		return Rule[any]{}, tokens, errors.New("expected `[`, got " + openBracket.String())
+		return Rule[any]{}, tokens, syntaxError(openBracket.Span, "expected `[`, got "+openBracket.String())
 	}
 
 	tokens = tokens[1:]
@@ -350,18 +356,20 @@ func parseArray(tokens []Token[any]) (Rule[any], []Token[any], error) {
 
 	var closeBracket = tokens[0]
 	if closeBracket.Id != tokenBracketClose {
-		return Rule[any]{}, tokens, errors.New("expected `]`, got " + closeBracket.String())
+		return Rule[any]{}, tokens, syntaxError(closeBracket.Span, "expected `]`, got "+closeBracket.String())
 	}
 
 	tokens = tokens[1:]
 
+	rule.Span = Span{Start: openBracket.Span.Start, End: closeBracket.Span.End}
+
 	return rule, tokens, nil
 }
 
 func parseString(tokens []Token[any]) (Rule[any], []Token[any], error) {
-	var quote = tokens[0]
-	if quote.Id != tokenDoubleQuote {
-		return Rule[any]{}, tokens, errors.New("expected `\"`, got " + quote.String())
+	var openQuote = tokens[0]
+	if openQuote.Id != tokenDoubleQuote {
+		return Rule[any]{}, tokens, syntaxError(openQuote.Span, "expected `\"`, got "+openQuote.String())
 	}
 
 	tokens = tokens[1:]
@@ -372,29 +380,35 @@ func parseString(tokens []Token[any]) (Rule[any], []Token[any], error) {
 	for token.Id != tokenDoubleQuote {
 		switch token.Id {
 		case tokenCharSequence:
-			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleCharSequence, Data: token.Data})
+			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleCharSequence, Data: token.Data, Span: token.Span})
 		case tokenCharEscape:
-			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleCharEscape, Data: token.Data})
+			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleCharEscape, Data: token.Data, Span: token.Span})
 		case tokenInput:
-			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleInput, Data: token.Data})
+			rule.Rules = append(rule.Rules, Rule[any]{Id: ruleInput, Data: token.Data, Span: token.Span})
 		default:
-			return Rule[any]{}, tokens, errors.New("expected one of `char_seq`, `char_escape` or `input`, got " + token.String())
+			return Rule[any]{}, tokens, syntaxError(token.Span, "expected one of `char_seq`, `char_escape` or `input`, got "+token.String())
 		}
 
 		tokens = tokens[1:]
 		token = tokens[0]
 	}
 
-	quote = tokens[0]
-	if quote.Id != tokenDoubleQuote {
-		return Rule[any]{}, tokens, errors.New("expected `\"`, got " + quote.String())
+	var closeQuote = tokens[0]
+	if closeQuote.Id != tokenDoubleQuote {
+		return Rule[any]{}, tokens, syntaxError(closeQuote.Span, "expected `\"`, got "+closeQuote.String())
 	}
 
 	tokens = tokens[1:]
 
+	rule.Span = Span{Start: openQuote.Span.Start, End: closeQuote.Span.End}
+
 	return rule, tokens, nil
 }
 
+// Parses the token stream produced by `tokenize`,
+// which is always terminated by a `tokenEOF` token.
+// The individual parse functions rely on this
+// to look ahead without checking the length of the stream.
 func parse(tokens []Token[any]) (Rule[any], error) {
 	var rule = Rule[any]{Id: ruleConfig}
 
@@ -419,6 +433,7 @@ func parse(tokens []Token[any]) (Rule[any], error) {
 		}
 
 		rule.Rules = append(rule.Rules, obj)
+		rule.Span = Span{Start: token.Span.Start, End: obj.Span.End}
 
 	case tokenBraceOpen:
 		var obj Rule[any]
@@ -430,9 +445,15 @@ func parse(tokens []Token[any]) (Rule[any], error) {
 		}
 
 		rule.Rules = append(rule.Rules, obj)
+		rule.Span = obj.Span
 
 	default:
-		return rule, errors.New("expected one of `let` or `{`, got " + token.String())
+		return rule, syntaxError(token.Span, "expected one of `let` or `{`, got "+token.String())
 	}
+
+	if tokens[0].Id != tokenEOF {
+		return rule, syntaxError(tokens[0].Span, "expected end of input, got "+tokens[0].String())
+	}
+
 	return rule, nil
 }
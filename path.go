@@ -0,0 +1,199 @@
+package corn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// Splits a dotted path such as `server.'host name'.port` into its segments.
+//
+// Segments follow the same rules as keys in Corn source,
+// so may be wrapped in single quotes to include dots or whitespace,
+// with `\'` used to escape a quote.
+func splitPath(path string) ([]string, error) {
+	var segments []string
+	var input = []rune(path)
+
+	for {
+		if len(input) > 0 && input[0] == '\'' {
+			sb := new(strings.Builder)
+			closed := false
+
+			for i := 1; i < len(input); i++ {
+				if input[i] == '\\' && i+1 < len(input) && input[i+1] == '\'' {
+					sb.WriteRune('\'')
+					i++
+				} else if input[i] == '\'' {
+					input = input[i+1:]
+					closed = true
+					break
+				} else {
+					sb.WriteRune(input[i])
+				}
+			}
+
+			if !closed {
+				return nil, errors.New("unterminated quoted segment in path `" + path + "`")
+			}
+
+			segments = append(segments, sb.String())
+		} else {
+			end := 0
+			for end < len(input) && input[end] != '.' {
+				end++
+			}
+
+			if end == 0 {
+				return nil, errors.New("empty segment in path `" + path + "`")
+			}
+
+			segments = append(segments, string(input[:end]))
+			input = input[end:]
+		}
+
+		if len(input) == 0 {
+			return segments, nil
+		}
+
+		if input[0] != '.' {
+			return nil, errors.New("expected `.` after quoted segment in path `" + path + "`")
+		}
+
+		input = input[1:]
+	}
+}
+
+// Joins path segments back into a dotted path,
+// quoting any segments which require it.
+func joinPath(segments []string) string {
+	parts := make([]string, len(segments))
+
+	for i, seg := range segments {
+		quoted, err := quotePathSegment(seg)
+
+		if err != nil {
+			quoted = seg
+		}
+
+		parts[i] = quoted
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Get returns the value at the dotted path within the evaluated object.
+//
+// Path segments use the same syntax as keys in Corn source,
+// so segments containing dots or whitespace can be wrapped in single quotes,
+// for example `servers.'eu-west.1'.host`.
+// Numeric segments index into arrays.
+func (evaluation Evaluation) Get(path string) (Value, error) {
+	segments, err := splitPath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lookupPath(evaluation.Value, segments)
+}
+
+func lookupPath(value Value, segments []string) (Value, error) {
+	for i, seg := range segments {
+		switch curr := value.(type) {
+		case *orderedmap.OrderedMap:
+			child, ok := curr.Get(seg)
+
+			if !ok {
+				return nil, errors.New("key `" + joinPath(segments[:i+1]) + "` does not exist")
+			}
+
+			value = child
+		case []Value:
+			index, err := strconv.Atoi(seg)
+
+			if err != nil || index < 0 || index >= len(curr) {
+				return nil, errors.New("invalid index `" + seg + "` into array at `" + joinPath(segments[:i]) + "` of length " + strconv.Itoa(len(curr)))
+			}
+
+			value = curr[index]
+		default:
+			return nil, errors.New("cannot look up `" + seg + "` in " + describeValue(value) + " at `" + joinPath(segments[:i]) + "`")
+		}
+	}
+
+	return value, nil
+}
+
+// GetString returns the string at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+func (evaluation Evaluation) GetString(path string) (string, error) {
+	return getTyped[string](evaluation, path, "string")
+}
+
+// GetInt returns the integer at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+func (evaluation Evaluation) GetInt(path string) (int64, error) {
+	return getTyped[int64](evaluation, path, "integer")
+}
+
+// GetFloat returns the float at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+// Integers are converted to floats.
+func (evaluation Evaluation) GetFloat(path string) (float64, error) {
+	value, err := evaluation.Get(path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	switch num := value.(type) {
+	case float64:
+		return num, nil
+	case int64:
+		return float64(num), nil
+	default:
+		return 0, getTypeError(path, value, "float")
+	}
+}
+
+// GetBool returns the boolean at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+func (evaluation Evaluation) GetBool(path string) (bool, error) {
+	return getTyped[bool](evaluation, path, "boolean")
+}
+
+// GetSlice returns the array at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+func (evaluation Evaluation) GetSlice(path string) ([]Value, error) {
+	return getTyped[[]Value](evaluation, path, "array")
+}
+
+// GetObject returns the object at the dotted path,
+// or an error if the path does not exist or holds another type of value.
+func (evaluation Evaluation) GetObject(path string) (*orderedmap.OrderedMap, error) {
+	return getTyped[*orderedmap.OrderedMap](evaluation, path, "object")
+}
+
+func getTyped[T any](evaluation Evaluation, path string, expected string) (T, error) {
+	var zero T
+	value, err := evaluation.Get(path)
+
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := value.(T)
+
+	if !ok {
+		return zero, getTypeError(path, value, expected)
+	}
+
+	return typed, nil
+}
+
+func getTypeError(path string, value Value, expected string) error {
+	return errors.New("value at `" + path + "` is " + describeValue(value) + ", expected " + expected)
+}
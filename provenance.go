@@ -0,0 +1,265 @@
+package corn
+
+import (
+	"strconv"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// An OriginKind describes what produced a value.
+type OriginKind int
+
+const (
+	// The value is written in the source,
+	// either in the object itself or in the declaration of an input.
+	OriginLiteral OriginKind = iota
+	// The value was read from an environment variable by an `$env_` input.
+	OriginEnv
+	// The value was supplied by the host application through `Options.Inputs`.
+	OriginInjected
+)
+
+func (kind OriginKind) String() string {
+	switch kind {
+	case OriginLiteral:
+		return "literal"
+	case OriginEnv:
+		return "env"
+	case OriginInjected:
+		return "injected"
+	default:
+		return "OriginKind(" + strconv.Itoa(int(kind)) + ")"
+	}
+}
+
+// An Origin records where a value in the evaluated output came from.
+// See `Options.Provenance`.
+type Origin struct {
+	Kind OriginKind
+
+	// The source which produced the value, and the position of its start.
+	//
+	// For literals this is the value as written, which may be within the declaration of an input.
+	// Objects created implicitly by a chained key such as `a.b.c` span the key.
+	// For environment variables and injected inputs it is the reference to the input.
+	Span     Span
+	Position Position
+
+	// The input the value was obtained through, including the `$`, if any.
+	// Where inputs reference other inputs, this is the innermost one.
+	Input string
+
+	// The environment variable which was read, for `OriginEnv`.
+	Env string
+
+	// The input whose spread produced the value, including the `$`, if any.
+	Spread string
+}
+
+// The origins of a value and of the values within it, mirroring its structure.
+// Values without a node of their own share the origin of their parent.
+type originNode struct {
+	origin   Origin
+	children map[string]*originNode
+	elements []*originNode
+}
+
+// Returns the node for the key within the object,
+// or a node sharing this node's origin if it has none.
+// Returns nil if the node is nil.
+func (node *originNode) child(key string) *originNode {
+	if node == nil {
+		return nil
+	}
+
+	if child, ok := node.children[key]; ok {
+		return child
+	}
+
+	return &originNode{origin: node.origin}
+}
+
+// Returns the node for the element at `index` within the array,
+// or a node sharing this node's origin if it has none.
+func (node *originNode) element(index int) *originNode {
+	if node == nil {
+		return nil
+	}
+
+	if index < len(node.elements) {
+		return node.elements[index]
+	}
+
+	return &originNode{origin: node.origin}
+}
+
+// Returns the node for the value at the path within the object, like `child`.
+func (node *originNode) at(path []string) *originNode {
+	for _, seg := range path {
+		node = node.child(seg)
+	}
+
+	return node
+}
+
+func (node *originNode) setChild(key string, child *originNode) {
+	if node.children == nil {
+		node.children = make(map[string]*originNode)
+	}
+
+	node.children[key] = child
+}
+
+// Returns a deep copy of the node,
+// calling `fill` on the origin of each copied node.
+func (node *originNode) clone(fill func(origin *Origin)) *originNode {
+	copied := &originNode{origin: node.origin}
+	fill(&copied.origin)
+
+	for key, child := range node.children {
+		copied.setChild(key, child.clone(fill))
+	}
+
+	for _, element := range node.elements {
+		copied.elements = append(copied.elements, element.clone(fill))
+	}
+
+	return copied
+}
+
+// Attributes values not already obtained through an input to `name`.
+func fillInput(name string) func(origin *Origin) {
+	return func(origin *Origin) {
+		if origin.Input == "" {
+			origin.Input = name
+		}
+	}
+}
+
+// Attributes values not already produced by another spread to the spread of `name`.
+func fillSpread(name string) func(origin *Origin) {
+	return func(origin *Origin) {
+		if origin.Spread == "" {
+			origin.Spread = name
+		}
+	}
+}
+
+// Records the origin of the value currently being evaluated, if provenance is enabled.
+func (evaluation Evaluation) record(origin Origin) {
+	if evaluation.origin != nil {
+		evaluation.origin.origin = origin
+	}
+}
+
+// Records the origins of a reference to a resolved input,
+// attributing them to the input.
+func (evaluation Evaluation) recordInput(name string) {
+	if evaluation.origin != nil {
+		*evaluation.origin = *evaluation.resolver.origins[name].clone(fillInput(name))
+	}
+}
+
+// Returns a new node to record origins into, or nil if provenance is not enabled.
+func (evaluation Evaluation) newOrigin() *originNode {
+	if evaluation.origin == nil {
+		return nil
+	}
+
+	return &originNode{}
+}
+
+// Returns a copy of the evaluation which records origins into `node`,
+// or nil to stop recording them.
+func (evaluation Evaluation) recordInto(node *originNode) Evaluation {
+	evaluation.origin = node
+	return evaluation
+}
+
+// Returns a copy of the evaluation which records into a new node for the value at `path` within `obj`.
+// Objects along the path which do not yet exist are attributed to the key, spanning `span`.
+func (evaluation Evaluation) recordPair(obj *orderedmap.OrderedMap, path []string, span Span) Evaluation {
+	if evaluation.origin == nil {
+		return evaluation
+	}
+
+	node := evaluation.origin
+
+	for _, seg := range path[:len(path)-1] {
+		var value Value
+		var exists bool
+
+		if obj != nil {
+			value, exists = obj.Get(seg)
+		}
+
+		child, ok := node.children[seg]
+
+		if !ok {
+			child = &originNode{origin: Origin{Kind: OriginLiteral, Span: span}}
+
+			// keys within spread or injected objects share the origin of their parent
+			if exists {
+				child.origin = node.origin
+			}
+
+			node.setChild(seg, child)
+		}
+
+		obj, _ = value.(*orderedmap.OrderedMap)
+		node = child
+	}
+
+	slot := &originNode{}
+	node.setChild(path[len(path)-1], slot)
+
+	return evaluation.recordInto(slot)
+}
+
+// Returns a copy of the evaluation which records into a new node for the next element of the array.
+func (evaluation Evaluation) recordElement() Evaluation {
+	if evaluation.origin == nil {
+		return evaluation
+	}
+
+	slot := &originNode{}
+	evaluation.origin.elements = append(evaluation.origin.elements, slot)
+
+	return evaluation.recordInto(slot)
+}
+
+// Builds the provenance map for the evaluated object,
+// keyed by paths in the form accepted by `Get`.
+// Returns nil if provenance is not enabled.
+func (evaluation Evaluation) provenance() map[string]Origin {
+	if evaluation.origin == nil || evaluation.Value == nil {
+		return nil
+	}
+
+	root := evaluation.origin
+	origins := make(map[string]Origin)
+
+	var walk func(value Value, node *originNode, path []string)
+	walk = func(value Value, node *originNode, path []string) {
+		origins[joinPath(path)] = node.origin
+
+		switch value := value.(type) {
+		case *orderedmap.OrderedMap:
+			for _, key := range value.Keys() {
+				child, _ := value.Get(key)
+				walk(child, node.child(key), append(path[:len(path):len(path)], key))
+			}
+		case []Value:
+			for i, element := range value {
+				walk(element, node.element(i), append(path[:len(path):len(path)], strconv.Itoa(i)))
+			}
+		}
+	}
+
+	for _, key := range evaluation.Value.Keys() {
+		value, _ := evaluation.Value.Get(key)
+		walk(value, root.child(key), []string{key})
+	}
+
+	return origins
+}
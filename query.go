@@ -0,0 +1,586 @@
+package corn
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// A Query selects values from an evaluated configuration.
+//
+// Queries are made up of steps, each applied to the values selected by the one before:
+//
+//	key, .key, 'quoted key'  the value of a key in an object
+//	['key']                  the same, using bracket notation
+//	*, .*, [*]               every value in an object or array
+//	[n]                      the element at an index in an array, counting from the end if negative
+//	[?condition]             every value in an object or array which matches the condition
+//	..key, ..*, ..[n]        a step applied to a value and every value nested within it
+//
+// Conditions compare a path relative to each value, or `@` for the value itself, with a literal,
+// for example `[?name == "api"]`, `[?port >= 8000]` or `[?@ != null]`.
+// The operators are `==`, `!=`, `<`, `<=`, `>` and `>=`.
+// A condition with only a path matches values where the path exists and is not `false` or `null`,
+// and values where the path does not exist never match a comparison.
+//
+// For example, `services[*].name` selects the name of every service,
+// `services[?enabled].name` the names of only those which are enabled,
+// and `..port` every `port` key at any depth.
+type Query struct {
+	source string
+	steps  []queryStep
+}
+
+type queryStepKind uint8
+
+const (
+	stepKey queryStepKind = iota
+	stepWildcard
+	stepIndex
+	stepFilter
+	stepDescend
+)
+
+type queryStep struct {
+	kind   queryStepKind
+	key    string
+	index  int
+	filter queryFilter
+}
+
+type queryFilter struct {
+	// path relative to the value being tested, empty for `@`
+	path []string
+	// comparison operator, or empty to test the value exists
+	op    string
+	value Value
+}
+
+// ParseQuery parses a query so that it can be applied to many values.
+func ParseQuery(query string) (*Query, error) {
+	p := &queryParser{query: query, input: []rune(query)}
+	steps, err := p.parse()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Query{source: query, steps: steps}, nil
+}
+
+// String returns the source of the query.
+func (query *Query) String() string {
+	return query.source
+}
+
+// Select returns every value matched by the query within `value`, in document order.
+// Steps which do not match, such as a missing key or an index out of range, select nothing.
+func (query *Query) Select(value Value) []Value {
+	current := []Value{value}
+
+	for _, step := range query.steps {
+		var next []Value
+
+		for _, value := range current {
+			next = step.apply(value, next)
+		}
+
+		current = next
+	}
+
+	return current
+}
+
+// Query returns every value matched by the query within the evaluated object.
+// See `Query` for the syntax.
+func (evaluation Evaluation) Query(query string) ([]Value, error) {
+	parsed, err := ParseQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Select(evaluation.Value), nil
+}
+
+// Appends the values selected by the step from `value` to `out`.
+func (step queryStep) apply(value Value, out []Value) []Value {
+	switch step.kind {
+	case stepKey:
+		if obj, ok := value.(*orderedmap.OrderedMap); ok {
+			if child, ok := obj.Get(step.key); ok {
+				out = append(out, child)
+			}
+		}
+	case stepWildcard:
+		out = append(out, children(value)...)
+	case stepIndex:
+		if arr, ok := value.([]Value); ok {
+			index := step.index
+
+			if index < 0 {
+				index += len(arr)
+			}
+
+			if index >= 0 && index < len(arr) {
+				out = append(out, arr[index])
+			}
+		}
+	case stepFilter:
+		for _, child := range children(value) {
+			if step.filter.matches(child) {
+				out = append(out, child)
+			}
+		}
+	case stepDescend:
+		out = append(out, value)
+
+		for _, child := range children(value) {
+			out = step.apply(child, out)
+		}
+	}
+
+	return out
+}
+
+// Returns the values of an object or the elements of an array.
+func children(value Value) []Value {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		var values []Value
+
+		for _, key := range value.Keys() {
+			child, _ := value.Get(key)
+			values = append(values, child)
+		}
+
+		return values
+	case []Value:
+		return value
+	default:
+		return nil
+	}
+}
+
+func (filter queryFilter) matches(value Value) bool {
+	target, err := lookupPath(value, filter.path)
+
+	if err != nil {
+		return false
+	}
+
+	switch filter.op {
+	case "":
+		return target != nil && target != false
+	case "==":
+		return compareValues(target, filter.value) == 0
+	case "!=":
+		return compareValues(target, filter.value) != 0
+	}
+
+	cmp := compareValues(target, filter.value)
+
+	// values which cannot be ordered never match
+	if cmp == incomparable {
+		return false
+	}
+
+	switch filter.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+const incomparable = 2
+
+// Compares two values, returning -1, 0 or 1 for numbers and strings,
+// 0 or `incomparable` for other values depending on whether they are equal.
+// Integers and floats are compared by value.
+func compareValues(a Value, b Value) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return compareOrdered(a, b)
+		case float64:
+			return compareOrdered(float64(a), b)
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return compareOrdered(a, float64(b))
+		case float64:
+			if math.IsNaN(a) || math.IsNaN(b) {
+				return incomparable
+			}
+
+			return compareOrdered(a, b)
+		}
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+
+	return incomparable
+}
+
+func compareOrdered[T int64 | float64](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Characters which end an unquoted key in a query.
+const charsQueryKeyEnd = " \t\r\n.[]=!<>"
+
+type queryParser struct {
+	query string
+	input []rune
+	pos   int
+}
+
+func (p *queryParser) errorAt(msg string) error {
+	return errors.New(msg + " at position " + strconv.Itoa(p.pos) + " in query `" + p.query + "`")
+}
+
+func (p *queryParser) peek() rune {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+
+	return 0
+}
+
+func (p *queryParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(p.input[p.pos:]), prefix)
+}
+
+func (p *queryParser) skipWhitespace() {
+	for p.pos < len(p.input) && strings.ContainsRune(charsWhitespace, p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *queryParser) expect(r rune) error {
+	if p.peek() != r {
+		return p.errorAt("expected `" + string(r) + "`")
+	}
+
+	p.pos++
+	return nil
+}
+
+func (p *queryParser) parse() ([]queryStep, error) {
+	var steps []queryStep
+
+	// a leading `.` refers to the root, as in jq
+	if p.hasPrefix(".") && !p.hasPrefix("..") {
+		p.pos++
+
+		if p.pos == len(p.input) {
+			return steps, nil
+		}
+
+		if p.peek() != '[' {
+			step, err := p.parseMember()
+
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		}
+	}
+
+	for p.pos < len(p.input) {
+		switch {
+		case p.hasPrefix(".."):
+			p.pos += 2
+			steps = append(steps, queryStep{kind: stepDescend})
+
+			// the step following `..` may be in bracket notation
+			if p.peek() == '[' {
+				continue
+			}
+
+			step, err := p.parseMember()
+
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		case p.peek() == '.':
+			p.pos++
+			step, err := p.parseMember()
+
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		case p.peek() == '[':
+			step, err := p.parseBracket()
+
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		case len(steps) == 0:
+			step, err := p.parseMember()
+
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		default:
+			return nil, p.errorAt("unexpected character '" + string(p.peek()) + "'")
+		}
+	}
+
+	return steps, nil
+}
+
+// Parses a key or wildcard following a `.`, or at the start of the query.
+func (p *queryParser) parseMember() (queryStep, error) {
+	if p.peek() == '*' {
+		p.pos++
+		return queryStep{kind: stepWildcard}, nil
+	}
+
+	key, err := p.parseKey()
+
+	if err != nil {
+		return queryStep{}, err
+	}
+
+	return queryStep{kind: stepKey, key: key}, nil
+}
+
+// Parses a key, which is either quoted like a path segment in source or runs until a separator.
+func (p *queryParser) parseKey() (string, error) {
+	if p.peek() == '\'' {
+		sb := new(strings.Builder)
+		p.pos++
+
+		for p.pos < len(p.input) {
+			r := p.input[p.pos]
+
+			if r == '\\' && p.pos+1 < len(p.input) && p.input[p.pos+1] == '\'' {
+				sb.WriteRune('\'')
+				p.pos += 2
+			} else if r == '\'' {
+				p.pos++
+				return sb.String(), nil
+			} else {
+				sb.WriteRune(r)
+				p.pos++
+			}
+		}
+
+		return "", p.errorAt("unterminated quoted key")
+	}
+
+	start := p.pos
+
+	for p.pos < len(p.input) && !strings.ContainsRune(charsQueryKeyEnd, p.input[p.pos]) {
+		p.pos++
+	}
+
+	if p.pos == start {
+		return "", p.errorAt("expected key")
+	}
+
+	return string(p.input[start:p.pos]), nil
+}
+
+// Parses a `[ ]` step containing a wildcard, index, quoted key or filter.
+func (p *queryParser) parseBracket() (queryStep, error) {
+	p.pos++
+	p.skipWhitespace()
+
+	var step queryStep
+
+	switch r := p.peek(); {
+	case r == '*':
+		p.pos++
+		step = queryStep{kind: stepWildcard}
+	case r == '\'':
+		key, err := p.parseKey()
+
+		if err != nil {
+			return step, err
+		}
+
+		step = queryStep{kind: stepKey, key: key}
+	case r == '?':
+		p.pos++
+		filter, err := p.parseFilter()
+
+		if err != nil {
+			return step, err
+		}
+
+		step = queryStep{kind: stepFilter, filter: filter}
+	case r == '-' || (r >= '0' && r <= '9'):
+		start := p.pos
+		p.pos++
+
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+
+		index, err := strconv.Atoi(string(p.input[start:p.pos]))
+
+		if err != nil {
+			p.pos = start
+			return step, p.errorAt("invalid index")
+		}
+
+		step = queryStep{kind: stepIndex, index: index}
+	default:
+		return step, p.errorAt("expected one of `*`, `?`, index or quoted key")
+	}
+
+	p.skipWhitespace()
+	return step, p.expect(']')
+}
+
+func (p *queryParser) parseFilter() (queryFilter, error) {
+	var filter queryFilter
+	p.skipWhitespace()
+
+	hasPath := p.peek() != '@'
+
+	if !hasPath {
+		p.pos++
+
+		// `@.key` is the same as `key`
+		if p.peek() == '.' {
+			p.pos++
+			hasPath = true
+		}
+	}
+
+	if hasPath {
+		for {
+			key, err := p.parseKey()
+
+			if err != nil {
+				return filter, err
+			}
+
+			filter.path = append(filter.path, key)
+
+			if p.peek() != '.' {
+				break
+			}
+
+			p.pos++
+		}
+	}
+
+	p.skipWhitespace()
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.hasPrefix(op) {
+			filter.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+
+	if filter.op == "" {
+		return filter, nil
+	}
+
+	p.skipWhitespace()
+
+	value, err := p.parseLiteral()
+	filter.value = value
+
+	return filter, err
+}
+
+// Parses a string, number, boolean or null to compare against in a filter.
+func (p *queryParser) parseLiteral() (Value, error) {
+	switch {
+	case p.peek() == '"':
+		start := p.pos
+		p.pos++
+
+		for p.pos < len(p.input) && p.input[p.pos] != '"' {
+			if p.input[p.pos] == '\\' {
+				p.pos++
+			}
+
+			p.pos++
+		}
+
+		if p.pos >= len(p.input) {
+			return nil, p.errorAt("unterminated string")
+		}
+
+		p.pos++
+		str, err := strconv.Unquote(string(p.input[start:p.pos]))
+
+		if err != nil {
+			p.pos = start
+			return nil, p.errorAt("invalid string")
+		}
+
+		return str, nil
+	case p.peek() == '\'':
+		return p.parseKey()
+	case p.hasPrefix("true"):
+		p.pos += 4
+		return true, nil
+	case p.hasPrefix("false"):
+		p.pos += 5
+		return false, nil
+	case p.hasPrefix("null"):
+		p.pos += 4
+		return nil, nil
+	}
+
+	start := p.pos
+
+	for p.pos < len(p.input) && strings.ContainsRune("+-.0123456789eE", p.input[p.pos]) {
+		p.pos++
+	}
+
+	text := string(p.input[start:p.pos])
+
+	if num, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return num, nil
+	}
+
+	if num, err := strconv.ParseFloat(text, 64); err == nil {
+		return num, nil
+	}
+
+	p.pos = start
+	return nil, p.errorAt("expected string, number, `true`, `false` or `null`")
+}
@@ -0,0 +1,66 @@
+package corn
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	input := `{
+    services = [
+        { name = "api" port = 8080 enabled = true tags = [ "web" ] }
+        { name = "worker" port = 9000 enabled = false }
+        { name = "db" port = 5432.0 }
+    ]
+    'eu.west' = { region = { port = 1 } }
+}`
+
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"services[*].name":                   `["api","worker","db"]`,
+		".services.*.name":                   `["api","worker","db"]`,
+		"services[0].name":                   `["api"]`,
+		"services[-1].name":                  `["db"]`,
+		"services[5].name":                   `null`,
+		"services[?enabled].name":            `["api"]`,
+		"services[?name == \"worker\"].port": `[9000]`,
+		"services[?port >= 5432].name":       `["api","worker","db"]`,
+		"services[? port < 8080.5 ].name":    `["api","db"]`,
+		"services[?tags.0 == 'web'].name":    `["api"]`,
+		"services[?enabled != true].name":    `["worker"]`,
+		"services[*].tags[?@ == \"web\"]":    `["web"]`,
+		"..port":                             `[8080,9000,5432,1]`,
+		"'eu.west'.region.port":              `[1]`,
+		"['eu.west']..port":                  `[1]`,
+		"missing.key":                        `null`,
+	}
+
+	for query, expected := range tests {
+		values, err := evaluation.Query(query)
+
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", query, err)
+			continue
+		}
+
+		actual, _ := json.Marshal(values)
+		assertEqual(t, query+" "+string(actual), query+" "+expected)
+	}
+
+	for _, query := range []string{"services[", "services[*", "a..", "a.'b", "a[x]", "a[?b ==]", "a b"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("expected error for %s", query)
+		}
+	}
+
+	whole, _ := evaluation.Query(".")
+
+	if len(whole) != 1 || whole[0] != evaluation.Value {
+		t.Fatalf("expected root object, got %v", whole)
+	}
+}
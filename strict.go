@@ -0,0 +1,104 @@
+package corn
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Reports inputs in the `let` block which are unused, declared more than once,
+// or shadowed by an environment variable, for `Options.Strict`.
+func checkInputs(ast Rule[any], evaluation Evaluation) error {
+	if len(ast.Rules) < 2 || ast.Rules[0].Id != ruleAssignBlock {
+		return nil
+	}
+
+	var issues []*EvalError
+	var declared = make(map[string]Span)
+	var refs = make(map[string][]string)
+
+	for _, assignment := range ast.Rules[0].Rules {
+		var nameRule = assignment.Rules[0]
+		var name = (*nameRule.Data).(string)
+
+		if _, ok := declared[name]; ok {
+			issues = append(issues, evalError(nameRule.Span, "input `"+name+"` is already declared"))
+		} else {
+			declared[name] = nameRule.Span
+		}
+
+		// only the last declaration is evaluated
+		refs[name] = nil
+
+		if strings.HasPrefix(name, "$env_") {
+			envName := name[len("$env_"):]
+
+			if _, ok := evaluation.lookupEnv(envName); ok && evaluation.env.Allows(envName) {
+				issues = append(issues, evalError(nameRule.Span, "input `"+name+"` is shadowed by environment variable `"+envName+"`"))
+				continue
+			}
+		}
+
+		if _, ok := evaluation.injected[name]; ok {
+			continue
+		}
+
+		inputRefs(assignment.Rules[1], func(ref string) {
+			refs[name] = append(refs[name], ref)
+		})
+	}
+
+	// inputs are used if they can be reached from the object,
+	// so inputs referenced only by unused inputs, or by themselves, are also unused
+	var used = make(map[string]bool)
+	var pending []string
+
+	inputRefs(ast.Rules[1], func(ref string) {
+		pending = append(pending, ref)
+	})
+
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if used[name] {
+			continue
+		}
+
+		used[name] = true
+		pending = append(pending, refs[name]...)
+	}
+
+	for name, span := range declared {
+		if !used[name] {
+			issues = append(issues, evalError(span, "input `"+name+"` is declared but never used"))
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Offset < issues[j].Offset
+	})
+
+	var errs []error
+
+	for _, issue := range issues {
+		errs = append(errs, issue)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Calls `f` with the name of every input referenced within the rule,
+// including in strings and spreads.
+func inputRefs(rule Rule[any], f func(name string)) {
+	switch rule.Id {
+	case ruleInput, ruleSpread:
+		f((*rule.Data).(string))
+	case ruleAssignment:
+		inputRefs(rule.Rules[1], f)
+	default:
+		for _, child := range rule.Rules {
+			inputRefs(child, f)
+		}
+	}
+}
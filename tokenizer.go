@@ -1,9 +1,11 @@
 package corn
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 type tokenId = int32
@@ -29,6 +31,9 @@ const (
 	tokenCharEscape
 	tokenCharSequence
 	tokenInput
+	tokenEOF
+	tokenComment
+	tokenWhitespace
 )
 
 const (
@@ -74,6 +79,7 @@ func matchRuleStateChange(matcher matcher, stateChange stateChanger) MatchRule {
 type Token[T comparable] struct {
 	Id   tokenId
 	Data *T
+	Span Span
 }
 
 func (t Token[Stringer]) String() string {
@@ -117,6 +123,12 @@ func (t Token[Stringer]) String() string {
 		identifier = "char_seq"
 	case tokenCharEscape:
 		identifier = "char_escape"
+	case tokenEOF:
+		identifier = "EOF"
+	case tokenComment:
+		identifier = "comment"
+	case tokenWhitespace:
+		identifier = "whitespace"
 
 	default:
 		identifier = "?"
@@ -201,13 +213,14 @@ func matchCompoundToken(tokenId tokenId, tokenChars []rune) matcher {
 
 func matchDynamicToken(tokenId tokenId, matcher dynamicTokenMatcher) matcher {
 	return func(input []rune, tokens []Token[any]) ([]rune, []Token[any], bool) {
-		var data any
-		input, data = matcher(input)
+		// matchers may consume input before failing,
+		// so only advance on success
+		rest, data := matcher(input)
 
 		if data != nil {
 			var token = dataToken(tokenId, data)
 			tokens = append(tokens, token)
-			return input, tokens, true
+			return rest, tokens, true
 		}
 
 		return input, tokens, false
@@ -242,18 +255,15 @@ func matchQuotedPathSegment(input []rune) ([]rune, any) {
 		return input, nil
 	}
 
+	// only the character directly after a backslash is escaped
 	escaping := false
 	path, input := takeWhile(input[1:], func(r rune) bool {
-		if r == '\\' {
-			escaping = true
+		if r == '\'' && !escaping {
+			return false
 		}
 
-		if r == '\'' && escaping {
-			escaping = false
-			return true
-		} else {
-			return r != '\''
-		}
+		escaping = r == '\\' && !escaping
+		return true
 	})
 
 	if input[0] != '\'' {
@@ -391,17 +401,21 @@ func matchCharSequence(input []rune) ([]rune, any) {
 		return !strings.ContainsRune(charsInvalidCharSequence, char)
 	})
 
+	if len(seq) == 0 {
+		return input, nil
+	}
+
 	return input, string(seq)
 }
 
 // TODO: Make more efficient - allocating on every token currently :(
-func getMatchers(state stateId) []MatchRule {
+func getMatchers(state stateId) ([]MatchRule, error) {
 	switch state {
 	case stateTopLevel:
 		return []MatchRule{
 			matchRuleStateChange(matchCompoundToken(tokenLet, []rune("let")), statePusher(stateAssignBlock)),
 			matchRuleStateChange(matchToken(tokenBraceOpen, '{'), statePusher(stateObject)),
-		}
+		}, nil
 	case stateAssignBlock:
 		return []MatchRule{
 			matchRuleStateChange(matchCompoundToken(tokenIn, []rune("in")), popState),
@@ -409,7 +423,7 @@ func getMatchers(state stateId) []MatchRule {
 			matchRule(matchToken(tokenBraceClose, '}')),
 			matchRule(matchDynamicToken(tokenInput, matchInput)),
 			matchRuleStateChange(matchToken(tokenEquals, '='), statePusher(stateValue)),
-		}
+		}, nil
 	case stateObject:
 		return []MatchRule{
 			matchRuleStateChange(matchToken(tokenBraceClose, '}'), popState),
@@ -420,7 +434,7 @@ func getMatchers(state stateId) []MatchRule {
 			matchRule(matchDynamicToken(tokenInput, matchInput)),
 			matchRule(matchDynamicToken(tokenPathSegment, matchQuotedPathSegment)),
 			matchRule(matchDynamicToken(tokenPathSegment, matchPathSegment)),
-		}
+		}, nil
 	case stateArray:
 		return []MatchRule{
 			matchRuleStateChange(matchToken(tokenBracketClose, ']'), popState),
@@ -439,7 +453,7 @@ func getMatchers(state stateId) []MatchRule {
 			matchRule(matchDynamicToken(tokenInput, matchInput)),
 			matchRule(matchDynamicToken(tokenFloat, matchFloat)),
 			matchRule(matchDynamicToken(tokenInteger, matchInteger)),
-		}
+		}, nil
 	case stateValue:
 		return []MatchRule{
 			matchRuleStateChange(matchToken(tokenBraceOpen, '{'), stateReplacer(stateObject)),
@@ -457,7 +471,7 @@ func getMatchers(state stateId) []MatchRule {
 			matchRuleStateChange(matchDynamicToken(tokenInteger, matchInteger), popState),
 
 			matchRuleStateChange(matchToken(tokenBracketClose, ']'), popState),
-		}
+		}, nil
 	case stateString:
 		return []MatchRule{
 			matchRuleStateChange(matchToken(tokenDoubleQuote, '"'), popState),
@@ -465,9 +479,9 @@ func getMatchers(state stateId) []MatchRule {
 			matchRule(matchDynamicToken(tokenInput, matchInput)),
 			matchRule(matchDynamicToken(tokenCharEscape, matchCharEscape)),
 			matchRule(matchDynamicToken(tokenCharSequence, matchCharSequence)),
-		}
+		}, nil
 	default:
-		panic("invalid state id " + strconv.Itoa(int(state)))
+		return nil, errors.New("invalid state id " + strconv.Itoa(int(state)))
 	}
 }
 
@@ -487,38 +501,111 @@ func popState(state []stateId) []stateId {
 	return state[:len(state)-1]
 }
 
-func tokenize(inputString string) []Token[any] {
-	var input = []rune(strings.TrimSpace(inputString))
+// Returns the byte offset of each rune in `str`,
+// plus a final entry for the end of the string,
+// each shifted by `base`.
+func runeOffsets(str string, base int) []int {
+	offsets := make([]int, 0, len(str)+1)
+
+	for i := range str {
+		offsets = append(offsets, base+i)
+	}
+
+	return append(offsets, base+len(str))
+}
+
+type scanMode = uint8
+
+const (
+	// emit `tokenComment` tokens rather than discarding comments
+	scanComments scanMode = 1 << iota
+	// emit `tokenWhitespace` tokens for whitespace outside strings,
+	// so that the tokens cover every byte of the input
+	scanWhitespace
+)
+
+// Splits the input into tokens, ending with a `tokenEOF` token.
+//
+// A `*SyntaxError` is returned if some part of the input
+// cannot be matched by any token in the current state.
+func tokenize(inputString string) ([]Token[any], error) {
+	return scan(inputString, 0)
+}
+
+// Tokenizes the input like `tokenize`,
+// additionally keeping the trivia selected by `mode`.
+func scan(inputString string, mode scanMode) ([]Token[any], error) {
+	var trimmed = strings.TrimSpace(inputString)
+	var runes = []rune(trimmed)
+	var offsets = runeOffsets(trimmed, len(inputString)-len(strings.TrimLeftFunc(inputString, unicode.IsSpace)))
+
+	var input = runes
 	var tokens []Token[any]
 	var state = []stateId{stateTopLevel}
 
+	// byte offset of the start of the remaining input
+	position := func() int {
+		return offsets[len(runes)-len(input)]
+	}
+
+	if mode&scanWhitespace != 0 && offsets[0] > 0 {
+		tokens = append(tokens, Token[any]{Id: tokenWhitespace, Span: Span{Start: 0, End: offsets[0]}})
+	}
+
 	for len(input) > 0 {
 		var length = len(input)
+
+		if len(state) == 0 {
+			return nil, syntaxError(Span{Start: position()}, "unexpected "+describeRune(input[0])+" after end of config")
+		}
+
 		var currentState = state[len(state)-1]
 
 		if currentState != stateString {
 			// handle whitespace
-			if strings.ContainsRune(charsWhitespace, input[0]) {
-				_, input = takeWhile(input, func(r rune) bool {
-					return strings.ContainsRune(charsWhitespace, r)
-				})
+			var start = position()
+
+			for len(input) > 0 && strings.ContainsRune(charsWhitespace, input[0]) {
+				input = input[1:]
+			}
+
+			if mode&scanWhitespace != 0 && position() > start {
+				tokens = append(tokens, Token[any]{Id: tokenWhitespace, Span: Span{Start: start, End: position()}})
 			}
 
 			// handle comments
 			if len(input) > 1 && input[0] == '/' && input[1] == '/' {
-				_, input = takeWhile(input, func(r rune) bool {
-					return r != '\n'
-				})
+				start = position()
+				var comment = input
+
+				for len(input) > 0 && input[0] != '\n' {
+					input = input[1:]
+				}
+
+				if mode&scanComments != 0 {
+					var text = string(comment[:len(comment)-len(input)])
+					var token = dataToken[any](tokenComment, text)
+					token.Span = Span{Start: start, End: position()}
+
+					tokens = append(tokens, token)
+				}
 			}
 		}
 
-		var matchers = getMatchers(currentState)
+		var matchers, err = getMatchers(currentState)
+		var start = position()
+
+		if err != nil {
+			return nil, syntaxError(Span{Start: start}, err.Error())
+		}
 
 		for _, matcher := range matchers {
 			var match bool
 			input, tokens, match = matcher.matcher(input, tokens)
 
 			if match {
+				tokens[len(tokens)-1].Span = Span{Start: start, End: position()}
+
 				if matcher.stateChange != nil {
 					state = matcher.stateChange(state)
 				}
@@ -528,9 +615,22 @@ func tokenize(inputString string) []Token[any] {
 		}
 
 		if len(input) == length {
-			panic("length unchanged")
+			return nil, syntaxError(Span{Start: start}, "unexpected "+describeRune(input[0]))
 		}
 	}
 
-	return tokens
+	var end = offsets[len(offsets)-1]
+
+	if mode&scanWhitespace != 0 && end < len(inputString) {
+		tokens = append(tokens, Token[any]{Id: tokenWhitespace, Span: Span{Start: end, End: len(inputString)}})
+		end = len(inputString)
+	}
+
+	tokens = append(tokens, Token[any]{Id: tokenEOF, Span: Span{Start: end, End: end}})
+
+	return tokens, nil
+}
+
+func describeRune(r rune) string {
+	return "character " + strconv.QuoteRune(r)
 }
@@ -0,0 +1,279 @@
+package corn
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// ToTOML returns the TOML encoding of an object.
+//
+// The value is converted in the same way as `Marshal`,
+// so may be an evaluated `Value` or any Go value which can be marshalled as Corn,
+// but must be an object at the top level.
+// Nested objects are written as tables and arrays of objects as arrays of tables,
+// each after the keys of their parent, while other values are written inline.
+// Key order is otherwise kept.
+//
+// TOML cannot represent null, so an error naming its path is returned if the value contains one.
+func ToTOML(v any) ([]byte, error) {
+	value, err := valueOf(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := value.(*orderedmap.OrderedMap)
+
+	if !ok {
+		return nil, errors.New("cannot write " + describeValue(value) + " as top-level TOML value, expected object")
+	}
+
+	enc := &tomlEncoder{}
+	err = enc.writeTable(obj, nil, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(enc.sb.String()), nil
+}
+
+// Writes objects as TOML tables.
+type tomlEncoder struct {
+	sb strings.Builder
+}
+
+// Writes the keys of a table which can be written inline,
+// followed by its sub-tables and arrays of tables.
+// `header` holds the keys of the table's header, and `path` its path for errors.
+func (enc *tomlEncoder) writeTable(obj *orderedmap.OrderedMap, header []string, path string) error {
+	var tables []string
+	var arrays []string
+
+	for _, key := range obj.Keys() {
+		value, _ := obj.Get(key)
+
+		if isTOMLTable(value) {
+			tables = append(tables, key)
+			continue
+		}
+
+		if isTOMLArrayOfTables(value) {
+			arrays = append(arrays, key)
+			continue
+		}
+
+		enc.sb.WriteString(tomlKey(key) + " = ")
+		err := enc.writeInline(value, keyPath(path, key))
+
+		if err != nil {
+			return err
+		}
+
+		enc.sb.WriteRune('\n')
+	}
+
+	for _, key := range tables {
+		value, _ := obj.Get(key)
+		table := value.(*orderedmap.OrderedMap)
+		tableHeader := append(append([]string{}, header...), key)
+
+		// tables containing only other tables are defined implicitly by their headers
+		if hasTOMLValues(table) {
+			enc.header("[" + tomlHeader(tableHeader) + "]")
+		}
+
+		err := enc.writeTable(table, tableHeader, keyPath(path, key))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range arrays {
+		value, _ := obj.Get(key)
+		tableHeader := append(append([]string{}, header...), key)
+
+		for i, element := range value.([]Value) {
+			enc.header("[[" + tomlHeader(tableHeader) + "]]")
+			err := enc.writeTable(element.(*orderedmap.OrderedMap), tableHeader, indexPath(keyPath(path, key), i))
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (enc *tomlEncoder) header(header string) {
+	if enc.sb.Len() > 0 {
+		enc.sb.WriteRune('\n')
+	}
+
+	enc.sb.WriteString(header + "\n")
+}
+
+// Writes a value on a single line, using inline tables for any objects.
+func (enc *tomlEncoder) writeInline(value Value, path string) error {
+	switch value := value.(type) {
+	case nil:
+		return errors.New("cannot write null at `" + path + "` as TOML")
+	case *orderedmap.OrderedMap:
+		keys := value.Keys()
+
+		if len(keys) == 0 {
+			enc.sb.WriteString("{}")
+			return nil
+		}
+
+		enc.sb.WriteString("{ ")
+
+		for i, key := range keys {
+			if i > 0 {
+				enc.sb.WriteString(", ")
+			}
+
+			enc.sb.WriteString(tomlKey(key) + " = ")
+
+			child, _ := value.Get(key)
+			err := enc.writeInline(child, keyPath(path, key))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		enc.sb.WriteString(" }")
+	case []Value:
+		enc.sb.WriteRune('[')
+
+		for i, child := range value {
+			if i > 0 {
+				enc.sb.WriteString(", ")
+			}
+
+			err := enc.writeInline(child, indexPath(path, i))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		enc.sb.WriteRune(']')
+	case string:
+		enc.sb.WriteString(tomlString(value))
+	case bool:
+		enc.sb.WriteString(strconv.FormatBool(value))
+	case int64:
+		enc.sb.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		enc.sb.WriteString(formatFloat(value))
+	default:
+		return errors.New("cannot write value of type " + reflect.TypeOf(value).String())
+	}
+
+	return nil
+}
+
+func isTOMLTable(value Value) bool {
+	obj, ok := value.(*orderedmap.OrderedMap)
+	return ok && len(obj.Keys()) > 0
+}
+
+// Returns whether the value is a non-empty array containing only objects.
+func isTOMLArrayOfTables(value Value) bool {
+	arr, ok := value.([]Value)
+
+	if !ok || len(arr) == 0 {
+		return false
+	}
+
+	for _, element := range arr {
+		if _, ok := element.(*orderedmap.OrderedMap); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether the table has any keys which are written directly beneath its header.
+func hasTOMLValues(obj *orderedmap.OrderedMap) bool {
+	for _, key := range obj.Keys() {
+		value, _ := obj.Get(key)
+
+		if !isTOMLTable(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tomlHeader(keys []string) string {
+	parts := make([]string, len(keys))
+
+	for i, key := range keys {
+		parts[i] = tomlKey(key)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Returns the key bare if it only contains letters, digits, `_` and `-`,
+// otherwise as a quoted string.
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(key)
+		}
+	}
+
+	return key
+}
+
+// Returns the string as a TOML basic string.
+func tomlString(str string) string {
+	sb := new(strings.Builder)
+	sb.WriteRune('"')
+
+	for _, r := range str {
+		switch r {
+		case '\\':
+			sb.WriteString("\\\\")
+		case '"':
+			sb.WriteString("\\\"")
+		case '\b':
+			sb.WriteString("\\b")
+		case '\f':
+			sb.WriteString("\\f")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\t':
+			sb.WriteString("\\t")
+		default:
+			if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+				code := strconv.FormatInt(int64(r), 16)
+				sb.WriteString("\\u" + strings.Repeat("0", 4-len(code)) + code)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+
+	sb.WriteRune('"')
+	return sb.String()
+}
@@ -0,0 +1,76 @@
+package corn
+
+import (
+	"testing"
+)
+
+func TestToTOML(t *testing.T) {
+	input := `{
+    title = "app \"one\""
+    ratio = 0.5
+    mixed = [ 1 { a = true } [ "x" ] ]
+    empty = {}
+    'odd key' = "tab\there"
+    server = { host = "localhost" tls = { enabled = true } }
+    nested.only.deep = 1
+    services = [
+        { name = "api" ports = [ 80 443 ] meta = { owner = "me" } }
+        { name = "worker" }
+    ]
+    after = 1
+}`
+
+	expected := `title = "app \"one\""
+ratio = 0.5
+mixed = [1, { a = true }, ["x"]]
+empty = {}
+"odd key" = "tab\there"
+after = 1
+
+[server]
+host = "localhost"
+
+[server.tls]
+enabled = true
+
+[nested.only]
+deep = 1
+
+[[services]]
+name = "api"
+ports = [80, 443]
+
+[services.meta]
+owner = "me"
+
+[[services]]
+name = "worker"
+`
+
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ToTOML(evaluation.Value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), expected)
+
+	evaluation, err = Evaluate(`{ servers = [ { host = null } ] }`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ToTOML(evaluation.Value)
+	assertEqual(t, err.Error(), "cannot write null at `servers[0].host` as TOML")
+
+	if _, err := ToTOML([]int{1}); err == nil {
+		t.Fatal("expected error for top-level array")
+	}
+}
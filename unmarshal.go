@@ -0,0 +1,522 @@
+package corn
+
+import (
+	"encoding"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+// An UnmarshalTypeError describes a Corn value
+// that could not be stored in a Go value of a specific type.
+type UnmarshalTypeError struct {
+	Value string       // description of the Corn value, eg "string" or "object"
+	Type  reflect.Type // type of the Go value it could not be assigned to
+	Path  string       // dotted path to the value within the Corn object
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	msg := "cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+
+	if e.Path != "" {
+		msg += " at `" + e.Path + "`"
+	}
+
+	return msg
+}
+
+// An InvalidUnmarshalError describes an invalid target passed to `Unmarshal`,
+// which must be a non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type // nil if the target was nil
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "unmarshal target must be a non-nil pointer, got nil"
+	}
+
+	if e.Type.Kind() != reflect.Pointer {
+		return "unmarshal target must be a non-nil pointer, got " + e.Type.String()
+	}
+
+	return "unmarshal target must be a non-nil pointer, got nil " + e.Type.String()
+}
+
+// Unmarshal evaluates the input Corn string
+// and stores the result in the value pointed to by `v`.
+//
+// Objects can be decoded into structs, maps with string keys,
+// `*orderedmap.OrderedMap` or an empty interface.
+// Struct fields are matched against object keys using the `corn` struct tag,
+// falling back to the field name (case-insensitively).
+// The tag follows the same format as `encoding/json`,
+// for example `corn:"name,omitempty"` or `corn:"-"`.
+//
+// Arrays can be decoded into slices, arrays or an empty interface.
+// `null` sets pointers, maps, slices and interfaces to nil
+// and leaves any other value unchanged.
+func Unmarshal(input string, v any) error {
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		return err
+	}
+
+	return evaluation.Unmarshal(v)
+}
+
+// Unmarshal stores the evaluated Corn object
+// in the value pointed to by `v`.
+//
+// See the package-level `Unmarshal` for the supported conversions.
+func (evaluation Evaluation) Unmarshal(v any) error {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	return unmarshalValue(evaluation.Value, rv.Elem(), "")
+}
+
+var (
+	orderedMapType      = reflect.TypeOf(orderedmap.OrderedMap{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func unmarshalValue(value Value, rv reflect.Value, path string) error {
+	if value == nil {
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			rv.SetZero()
+		}
+
+		return nil
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		return unmarshalValue(value, rv.Elem(), path)
+	}
+
+	if str, ok := value.(string); ok && rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+
+		if err != nil {
+			return errors.New("cannot unmarshal `" + path + "`: " + err.Error())
+		}
+
+		return nil
+	}
+
+	if rv.Type() == orderedMapType {
+		obj, ok := value.(*orderedmap.OrderedMap)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		rv.Set(reflect.ValueOf(obj).Elem())
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return typeError(value, rv, path)
+		}
+
+		rv.Set(reflect.ValueOf(value))
+	case reflect.Struct:
+		obj, ok := value.(*orderedmap.OrderedMap)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		return unmarshalStruct(obj, rv, path)
+	case reflect.Map:
+		obj, ok := value.(*orderedmap.OrderedMap)
+
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return typeError(value, rv, path)
+		}
+
+		return unmarshalMap(obj, rv, path)
+	case reflect.Slice:
+		arr, ok := value.([]Value)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		slice := reflect.MakeSlice(rv.Type(), len(arr), len(arr))
+
+		for i, item := range arr {
+			err := unmarshalValue(item, slice.Index(i), indexPath(path, i))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		rv.Set(slice)
+	case reflect.Array:
+		arr, ok := value.([]Value)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(arr) {
+				rv.Index(i).SetZero()
+				continue
+			}
+
+			err := unmarshalValue(arr[i], rv.Index(i), indexPath(path, i))
+
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		str, ok := value.(string)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		rv.SetString(str)
+	case reflect.Bool:
+		b, ok := value.(bool)
+
+		if !ok {
+			return typeError(value, rv, path)
+		}
+
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, ok := value.(int64)
+
+		if !ok || rv.OverflowInt(num) {
+			return typeError(value, rv, path)
+		}
+
+		rv.SetInt(num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		num, ok := value.(int64)
+
+		if !ok || num < 0 || rv.OverflowUint(uint64(num)) {
+			return typeError(value, rv, path)
+		}
+
+		rv.SetUint(uint64(num))
+	case reflect.Float32, reflect.Float64:
+		var num float64
+
+		switch value := value.(type) {
+		case float64:
+			num = value
+		case int64:
+			num = float64(value)
+		default:
+			return typeError(value, rv, path)
+		}
+
+		if rv.Kind() == reflect.Float32 && math.Abs(num) > math.MaxFloat32 {
+			return typeError(value, rv, path)
+		}
+
+		rv.SetFloat(num)
+	default:
+		return typeError(value, rv, path)
+	}
+
+	return nil
+}
+
+func unmarshalStruct(obj *orderedmap.OrderedMap, rv reflect.Value, path string) error {
+	fields := typeFields(rv.Type())
+
+	for _, key := range obj.Keys() {
+		field, ok := findField(fields, key)
+
+		if !ok {
+			continue
+		}
+
+		fieldValue, err := fieldByIndex(rv, field.index)
+
+		if err != nil {
+			return errors.New("cannot unmarshal `" + keyPath(path, key) + "`: " + err.Error())
+		}
+
+		value, _ := obj.Get(key)
+		err = unmarshalValue(value, fieldValue, keyPath(path, key))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmarshalMap(obj *orderedmap.OrderedMap, rv reflect.Value, path string) error {
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(rv.Type(), len(obj.Keys())))
+	}
+
+	elemType := rv.Type().Elem()
+
+	for _, key := range obj.Keys() {
+		value, _ := obj.Get(key)
+
+		elem := reflect.New(elemType).Elem()
+		err := unmarshalValue(value, elem, keyPath(path, key))
+
+		if err != nil {
+			return err
+		}
+
+		rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+	}
+
+	return nil
+}
+
+// Walks the index of a promoted field,
+// allocating any nil embedded struct pointers along the way.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, errors.New("cannot set embedded pointer to unexported struct " + rv.Type().Elem().String())
+				}
+
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+
+			rv = rv.Elem()
+		}
+
+		rv = rv.Field(x)
+	}
+
+	return rv, nil
+}
+
+func typeError(value Value, rv reflect.Value, path string) error {
+	return &UnmarshalTypeError{Value: describeValue(value), Type: rv.Type(), Path: path}
+}
+
+// Returns a short description of a Corn value's type for use in error messages.
+func describeValue(value Value) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case *orderedmap.OrderedMap:
+		return "object"
+	case []Value:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
+
+func keyPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func indexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// A struct field which can be read from or written to a Corn object key.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// Returns the fields of struct type `t` that take part in (un)marshalling,
+// in declaration order.
+// Fields of embedded structs without an explicit name are promoted,
+// with shallower fields taking precedence over deeper ones.
+// As with `encoding/json`, fields with the same name at the same depth are dropped
+// unless exactly one of them is named by a tag.
+func typeFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+
+	fields, _ := fieldCache.LoadOrStore(t, collectFields(t))
+	return fields.([]field)
+}
+
+func collectFields(t reflect.Type) []field {
+	var fields []field
+	seen := make(map[string]bool)
+
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	type candidate struct {
+		field
+		tagged bool
+	}
+
+	current := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(current) > 0 {
+		var next []embedded
+		var level []candidate
+
+		for _, e := range current {
+			// a type embedded at a shallower depth has already contributed its fields,
+			// which would hide any found here
+			if visited[e.typ] {
+				continue
+			}
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+
+				tag := sf.Tag.Get("corn")
+				if tag == "-" {
+					continue
+				}
+
+				name, opts, _ := strings.Cut(tag, ",")
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if sf.Anonymous && name == "" {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{typ: ft, index: index})
+						continue
+					}
+				}
+
+				if !sf.IsExported() {
+					continue
+				}
+
+				tagged := name != ""
+
+				if !tagged {
+					name = sf.Name
+				}
+
+				level = append(level, candidate{
+					field: field{
+						name:      name,
+						index:     index,
+						omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+					},
+					tagged: tagged,
+				})
+			}
+		}
+
+		for i, c := range level {
+			if seen[c.name] {
+				continue
+			}
+
+			// hide deeper fields with the name whether or not this depth is ambiguous
+			seen[c.name] = true
+
+			dominant, count, tagged := c, 1, 0
+
+			if c.tagged {
+				tagged++
+			}
+
+			for _, other := range level[i+1:] {
+				if other.name != c.name {
+					continue
+				}
+
+				count++
+
+				if other.tagged {
+					dominant = other
+					tagged++
+				}
+			}
+
+			if count == 1 || tagged == 1 {
+				fields = append(fields, dominant.field)
+			}
+		}
+
+		// marked only once the depth is complete, so that the fields of a type
+		// embedded more than once at the same depth are dropped as ambiguous
+		for _, e := range current {
+			visited[e.typ] = true
+		}
+
+		current = next
+	}
+
+	slices.SortFunc(fields, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+
+	return fields
+}
+
+// Finds the field for an object key,
+// preferring an exact match over a case-insensitive one.
+func findField(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+
+	return field{}, false
+}
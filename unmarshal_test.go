@@ -0,0 +1,235 @@
+package corn
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+type testServer struct {
+	Host    string
+	Port    uint16 `corn:"port"`
+	Started time.Time
+	Tags    []string `corn:"tags,omitempty"`
+	Ignored string   `corn:"-"`
+}
+
+type testBase struct {
+	Name string `corn:"name"`
+}
+
+type testConfig struct {
+	testBase
+	Server  testServer             `corn:"server"`
+	Backup  *testServer            `corn:"backup"`
+	Limits  map[string]int         `corn:"limits"`
+	Ratio   float32                `corn:"ratio"`
+	Enabled bool                   `corn:"enabled"`
+	Extra   any                    `corn:"extra"`
+	Raw     *orderedmap.OrderedMap `corn:"raw"`
+	Pair    [2]int                 `corn:"pair"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	input := `let {
+		$host = "localhost"
+	} in {
+		name = "test"
+		server.host = $host
+		server.port = 8080
+		server.Started = "2024-01-02T03:04:05Z"
+		server.tags = [ "a" "b" ]
+		server.Ignored = "nope"
+		backup = { host = "backup" port = 8081 }
+		limits = { cpu = 2 mem = 512 }
+		ratio = 0.5
+		enabled = true
+		extra = [ 1 "two" null ]
+		raw = { a = 1 }
+		pair = [ 1 2 ]
+	}`
+
+	var config testConfig
+	err := Unmarshal(input, &config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testConfig{
+		testBase: testBase{Name: "test"},
+		Server: testServer{
+			Host:    "localhost",
+			Port:    8080,
+			Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Tags:    []string{"a", "b"},
+		},
+		Backup:  &testServer{Host: "backup", Port: 8081},
+		Limits:  map[string]int{"cpu": 2, "mem": 512},
+		Ratio:   0.5,
+		Enabled: true,
+		Extra:   []Value{int64(1), "two", nil},
+		Pair:    [2]int{1, 2},
+	}
+
+	expected.Raw = config.Raw
+	if config.Raw == nil || len(config.Raw.Keys()) != 1 {
+		t.Fatalf("expected raw object with one key, got %v", config.Raw)
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, config)
+	}
+}
+
+type testRecursive struct {
+	*testRecursive
+	A int `corn:"a"`
+}
+
+type testLeft struct {
+	Name   string
+	Shared string
+}
+
+type testRight struct {
+	Name   string `corn:"Name"`
+	Shared string
+}
+
+type testAmbiguous struct {
+	testLeft
+	testRight
+	Port int `corn:"port"`
+}
+
+type testLeftAgain struct{ testLeft }
+
+type testDiamondPeer struct{ testLeft }
+
+type testDiamond struct {
+	testLeftAgain
+	*testLeft
+}
+
+func TestUnmarshalEmbedded(t *testing.T) {
+	var recursive testRecursive
+	err := Unmarshal(`{ a = 1 }`, &recursive)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recursive.A != 1 {
+		t.Fatalf("expected a = 1, got %+v", recursive)
+	}
+
+	var ambiguous testAmbiguous
+	err = Unmarshal(`{ Name = "right" Shared = "dropped" port = 80 }`, &ambiguous)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testAmbiguous{testRight: testRight{Name: "right"}, Port: 80}
+
+	if !reflect.DeepEqual(ambiguous, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, ambiguous)
+	}
+
+	output, err := Marshal(ambiguous)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), `{ Name = "right" port = 80 }`)
+
+	// the shallower embedding of `testLeft` hides the deeper one
+	output, err = Marshal(testDiamond{testLeftAgain{testLeft{Name: "deep"}}, &testLeft{Name: "shallow"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), `{ Name = "shallow" Shared = "" }`)
+
+	// embedding the same type twice at one depth makes its fields ambiguous
+	output, err = Marshal(struct {
+		testLeftAgain
+		Other struct{ testLeft }
+		testDiamondPeer
+	}{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), `{ Other = { Name = "" Shared = "" } }`)
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	var config testConfig
+	err := Unmarshal(`{ server.port = "high" }`, &config)
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected UnmarshalTypeError, got %v", err)
+	}
+
+	if typeErr.Path != "server.port" || typeErr.Value != "string" {
+		t.Fatalf("unexpected error %v", typeErr)
+	}
+
+	err = Unmarshal(`{ server.port = 70000 }`, &config)
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected overflow error, got %v", err)
+	}
+}
+
+func TestUnmarshalNonPointer(t *testing.T) {
+	var config testConfig
+
+	targets := map[string]any{
+		"unmarshal target must be a non-nil pointer, got corn.testConfig":      config,
+		"unmarshal target must be a non-nil pointer, got nil":                  nil,
+		"unmarshal target must be a non-nil pointer, got nil *corn.testConfig": (*testConfig)(nil),
+	}
+
+	for expected, target := range targets {
+		err := Unmarshal(`{ name = "test" }`, target)
+
+		var invalidErr *InvalidUnmarshalError
+		if !errors.As(err, &invalidErr) {
+			t.Fatalf("expected InvalidUnmarshalError for %T, got %v", target, err)
+		}
+
+		assertEqual(t, err.Error(), expected)
+	}
+
+	if err := NewDecoder(strings.NewReader(`{ name = "test" }`)).Decode(nil); err == nil {
+		t.Fatal("expected error for nil decoder target")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{ name = "test" server.port = 80 }`))
+
+	var config testConfig
+	if err := dec.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Name != "test" || config.Server.Port != 80 {
+		t.Fatalf("unexpected result %+v", config)
+	}
+
+	if err := dec.Decode(&config); err != io.EOF {
+		t.Fatalf("expected io.EOF on second decode, got %v", err)
+	}
+}
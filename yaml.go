@@ -0,0 +1,277 @@
+package corn
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+const yamlIndent = "  "
+
+// ToYAML returns the YAML encoding of a value.
+//
+// The value is converted in the same way as `Marshal`,
+// so may be an evaluated `Value` or any Go value which can be marshalled as Corn.
+// Objects keep their key order, and are written in block style,
+// as are arrays, with empty objects and arrays written as `{}` and `[]`.
+// Strings spanning several lines are written as literal block scalars,
+// other strings are written unquoted unless they would be read back as another type.
+func ToYAML(v any) ([]byte, error) {
+	value, err := valueOf(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &yamlEncoder{}
+	err = enc.writeValue(value, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(enc.sb.String()), nil
+}
+
+// Writes values as YAML in block style.
+type yamlEncoder struct {
+	sb strings.Builder
+}
+
+func (enc *yamlEncoder) indent(depth int) {
+	enc.sb.WriteString(strings.Repeat(yamlIndent, depth))
+}
+
+// Writes a value which starts its own line at the given depth.
+func (enc *yamlEncoder) writeValue(value Value, depth int) error {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		if len(value.Keys()) > 0 {
+			return enc.writeObject(value, depth, false)
+		}
+	case []Value:
+		if len(value) > 0 {
+			return enc.writeArray(value, depth, false)
+		}
+	}
+
+	enc.indent(depth)
+	return enc.writeScalar(value, depth)
+}
+
+// Writes each key of an object on its own line.
+// If `inline` is set, the first key continues the current line, following a `- `.
+func (enc *yamlEncoder) writeObject(obj *orderedmap.OrderedMap, depth int, inline bool) error {
+	for i, key := range obj.Keys() {
+		if i > 0 || !inline {
+			enc.indent(depth)
+		}
+
+		enc.sb.WriteString(yamlString(key))
+		enc.sb.WriteRune(':')
+
+		value, _ := obj.Get(key)
+		err := enc.writeChild(value, depth)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes each element of an array on its own line, prefixed with `- `.
+// If `inline` is set, the first element continues the current line, following a `- `.
+func (enc *yamlEncoder) writeArray(arr []Value, depth int, inline bool) error {
+	for i, value := range arr {
+		if i > 0 || !inline {
+			enc.indent(depth)
+		}
+
+		enc.sb.WriteRune('-')
+
+		var err error
+
+		switch value := value.(type) {
+		case *orderedmap.OrderedMap:
+			if len(value.Keys()) > 0 {
+				enc.sb.WriteRune(' ')
+				err = enc.writeObject(value, depth+1, true)
+				break
+			}
+
+			err = enc.writeChild(value, depth)
+		case []Value:
+			if len(value) > 0 {
+				enc.sb.WriteRune(' ')
+				err = enc.writeArray(value, depth+1, true)
+				break
+			}
+
+			err = enc.writeChild(value, depth)
+		default:
+			err = enc.writeChild(value, depth)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes the value of a key or array element following its `:` or `-`.
+// Non-empty objects and arrays start on the next line, nested one level deeper.
+func (enc *yamlEncoder) writeChild(value Value, depth int) error {
+	switch value := value.(type) {
+	case *orderedmap.OrderedMap:
+		if len(value.Keys()) > 0 {
+			enc.sb.WriteRune('\n')
+			return enc.writeObject(value, depth+1, false)
+		}
+	case []Value:
+		if len(value) > 0 {
+			enc.sb.WriteRune('\n')
+			return enc.writeArray(value, depth+1, false)
+		}
+	}
+
+	enc.sb.WriteRune(' ')
+	return enc.writeScalar(value, depth)
+}
+
+// Writes a scalar or empty container, followed by a line break.
+// Block scalars are indented one level deeper than `depth`.
+func (enc *yamlEncoder) writeScalar(value Value, depth int) error {
+	switch value := value.(type) {
+	case nil:
+		enc.sb.WriteString("null")
+	case *orderedmap.OrderedMap:
+		enc.sb.WriteString("{}")
+	case []Value:
+		enc.sb.WriteString("[]")
+	case string:
+		if isBlockYAML(value) {
+			enc.writeBlockString(value, depth+1)
+			return nil
+		}
+
+		enc.sb.WriteString(yamlString(value))
+	case bool:
+		enc.sb.WriteString(strconv.FormatBool(value))
+	case int64:
+		enc.sb.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		enc.sb.WriteString(formatFloat(value))
+	default:
+		return errors.New("cannot write value of type " + reflect.TypeOf(value).String())
+	}
+
+	enc.sb.WriteRune('\n')
+	return nil
+}
+
+// Writes a multi-line string as a literal block scalar,
+// using the chomping indicator which keeps its trailing line breaks.
+func (enc *yamlEncoder) writeBlockString(str string, depth int) {
+	content := strings.TrimRight(str, "\n")
+	trailing := len(str) - len(content)
+
+	enc.sb.WriteRune('|')
+
+	// the indent, relative to the parent, is otherwise detected from the first line with content
+	if strings.HasPrefix(strings.TrimLeft(content, "\n"), " ") {
+		enc.sb.WriteString(strconv.Itoa(len(yamlIndent)))
+	}
+
+	switch trailing {
+	case 0:
+		enc.sb.WriteRune('-')
+	case 1:
+	default:
+		enc.sb.WriteRune('+')
+	}
+
+	enc.sb.WriteRune('\n')
+
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" {
+			enc.indent(depth)
+			enc.sb.WriteString(line)
+		}
+
+		enc.sb.WriteRune('\n')
+	}
+
+	// the final line break is part of the last line
+	for i := 1; i < trailing; i++ {
+		enc.sb.WriteRune('\n')
+	}
+}
+
+// Returns whether a string should be written as a literal block scalar.
+// Strings containing other control characters cannot be, as they must be escaped.
+func isBlockYAML(str string) bool {
+	if !strings.Contains(str, "\n") || strings.TrimSpace(str) == "" {
+		return false
+	}
+
+	for _, r := range str {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the string as a YAML scalar,
+// double-quoted if it would not be read back as the same string when written plain.
+func yamlString(str string) string {
+	if isPlainYAML(str) {
+		return str
+	}
+
+	return strconv.Quote(str)
+}
+
+// Words which YAML 1.1 or 1.2 parsers read as booleans or null,
+// along with the YAML 1.1 merge key `<<` and value key `=`.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true, "<<": true, "=": true,
+}
+
+func isPlainYAML(str string) bool {
+	if str == "" || strings.TrimSpace(str) != str || yamlReserved[strings.ToLower(str)] {
+		return false
+	}
+
+	// numbers, dates and times all start with a digit, sign or `.`
+	if strings.ContainsRune("-+.0123456789", rune(str[0])) {
+		return false
+	}
+
+	// indicators which have special meaning at the start of a scalar
+	if strings.ContainsRune("?:,[]{}#&*!|>'\"%@`", rune(str[0])) {
+		return false
+	}
+
+	if strings.Contains(str, ": ") || strings.Contains(str, " #") || strings.HasSuffix(str, ":") {
+		return false
+	}
+
+	for _, r := range str {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
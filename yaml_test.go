@@ -0,0 +1,99 @@
+package corn
+
+import (
+	"testing"
+)
+
+func TestToYAML(t *testing.T) {
+	input := `{
+    name = "app"
+    version = "1.0"
+    enabled = true
+    count = 3
+    ratio = 1.0
+    nothing = null
+    empty = {}
+    none = []
+    quoted = [ "true" "null" "" " padded" "a: b" "-dash" "tab\tin" "#tag" "<<" "=" ]
+    script = "
+        echo one
+          echo two
+    "
+    indented = "\n  first\nsecond"
+    'odd key' = 1
+    '<<' = "<< not a merge"
+    servers = [
+        { host = "a" ports = [ 80 443 ] }
+        [ 1 [ 2 ] ]
+        {}
+    ]
+}`
+
+	expected := `name: app
+version: "1.0"
+enabled: true
+count: 3
+ratio: 1.0
+nothing: null
+empty: {}
+none: []
+quoted:
+  - "true"
+  - "null"
+  - ""
+  - " padded"
+  - "a: b"
+  - "-dash"
+  - "tab\tin"
+  - "#tag"
+  - "<<"
+  - "="
+script: |2
+      echo one
+        echo two
+indented: |2-
+
+    first
+  second
+odd key: 1
+"<<": << not a merge
+servers:
+  - host: a
+    ports:
+      - 80
+      - 443
+  - - 1
+    - - 2
+  - {}
+`
+
+	evaluation, err := Evaluate(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ToYAML(evaluation.Value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, string(output), expected)
+
+	blocks := map[string]string{
+		"a\nb":       "|-\n  a\n  b\n",
+		"a\n\n":      "|+\n  a\n\n",
+		"\n  a\nb\n": "|2\n\n    a\n  b\n",
+	}
+
+	for str, expected := range blocks {
+		output, err := ToYAML(str)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertEqual(t, string(output), expected)
+	}
+}